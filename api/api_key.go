@@ -0,0 +1,221 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+)
+
+// ^ never carries the key itself, only the public prefix
+type apiKeyResponse struct {
+	ID        int64     `json:"id"`
+	Owner     string    `json:"owner"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scopes    []string  `json:"scopes"`
+	ExpiredAt time.Time `json:"expired_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newApiKeyResponse(apiKey db.ApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:        apiKey.ID,
+		Owner:     apiKey.Owner,
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		ExpiredAt: apiKey.ExpiredAt,
+		CreatedAt: apiKey.CreatedAt,
+	}
+}
+
+type createApiKeyRequest struct {
+	Name      string    `json:"name" binding:"required"`
+	Scopes    []string  `json:"scopes" binding:"required,min=1,dive,scope"`
+	ExpiredAt time.Time `json:"expired_at" binding:"required"`
+}
+
+// ! the key is only returned here, the database keeps a hash of it
+type createApiKeyResponse struct {
+	Key    string         `json:"key"`
+	ApiKey apiKeyResponse `json:"api_key"`
+}
+
+func (server *Server) createApiKey(ctx *gin.Context) {
+
+	var req createApiKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !req.ExpiredAt.After(time.Now()) {
+		err := errors.New("expired_at must be in the future")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if time.Until(req.ExpiredAt) > server.config.ApiKeyMaxDuration {
+		err := fmt.Errorf("api keys can live at most %s", server.config.ApiKeyMaxDuration)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	prefix, key, err := util.NewApiKey()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	apiKey, err := server.store.CreateApiKey(ctx, db.CreateApiKeyParams{
+		Owner:     authPayload.Username,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   util.HashSecretCode(key),
+		Scopes:    req.Scopes,
+		ExpiredAt: req.ExpiredAt,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, createApiKeyResponse{
+		Key:    key,
+		ApiKey: newApiKeyResponse(apiKey),
+	})
+}
+
+type listApiKeysRequest struct {
+	PageID   int32 `form:"page_id"  binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=1,max=10"`
+}
+
+func (server *Server) listApiKeys(ctx *gin.Context) {
+
+	var req listApiKeysRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	apiKeys, err := server.store.ListApiKeys(ctx, db.ListApiKeysParams{
+		Owner:  authPayload.Username,
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]apiKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		rsp = append(rsp, newApiKeyResponse(apiKey))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type apiKeyUriRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getApiKey(ctx *gin.Context) {
+
+	apiKey, ok := server.ownedApiKey(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newApiKeyResponse(apiKey))
+}
+
+// ^ the key itself and its expiry cannot change, a new key is needed for that
+type updateApiKeyRequest struct {
+	Name   *string  `json:"name" binding:"omitempty,min=1"`
+	Scopes []string `json:"scopes" binding:"omitempty,min=1,dive,scope"`
+}
+
+func (server *Server) updateApiKey(ctx *gin.Context) {
+
+	var req updateApiKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	apiKey, ok := server.ownedApiKey(ctx)
+	if !ok {
+		return
+	}
+
+	arg := db.UpdateApiKeyParams{
+		ID:     apiKey.ID,
+		Scopes: req.Scopes,
+	}
+	if req.Name != nil {
+		arg.Name = sql.NullString{String: *req.Name, Valid: true}
+	}
+
+	apiKey, err := server.store.UpdateApiKey(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newApiKeyResponse(apiKey))
+}
+
+func (server *Server) deleteApiKey(ctx *gin.Context) {
+
+	apiKey, ok := server.ownedApiKey(ctx)
+	if !ok {
+		return
+	}
+
+	if err := server.store.DeleteApiKey(ctx, apiKey.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// * loads the key from the uri, someone else's key is reported as not found
+// * so ids cannot be probed
+func (server *Server) ownedApiKey(ctx *gin.Context) (db.ApiKey, bool) {
+
+	var uri apiKeyUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.ApiKey{}, false
+	}
+
+	apiKey, err := server.store.GetApiKey(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return db.ApiKey{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.ApiKey{}, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if apiKey.Owner != authPayload.Username {
+		ctx.JSON(http.StatusNotFound, errorResponse(sql.ErrNoRows))
+		return db.ApiKey{}, false
+	}
+
+	return apiKey, true
+}
@@ -0,0 +1,535 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mockdb "github.com/itsadijmbt/simple_bank/db/mock"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func randomApiKey(t *testing.T, owner string, scopes ...string) (db.ApiKey, string) {
+	prefix, key, err := util.NewApiKey()
+	require.NoError(t, err)
+
+	apiKey := db.ApiKey{
+		ID:        util.RandomInt(1, 1000),
+		Owner:     owner,
+		Name:      util.RandomOwner(),
+		Prefix:    prefix,
+		KeyHash:   util.HashSecretCode(key),
+		Scopes:    scopes,
+		ExpiredAt: time.Now().Add(time.Hour),
+		CreatedAt: time.Now(),
+	}
+	return apiKey, key
+}
+
+func addApiKeyAuthorization(request *http.Request, key string) {
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("ApiKey %s", key))
+}
+
+func TestCreateApiKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"name":       "nightly batch",
+				"scopes":     []string{util.ScopeAccountsRead, util.ScopeTransfersWrite},
+				"expired_at": time.Now().Add(time.Hour),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateApiKeyParams) (db.ApiKey, error) {
+						require.Equal(t, user.Username, arg.Owner)
+						require.Equal(t, "nightly batch", arg.Name)
+						require.Equal(t, []string{util.ScopeAccountsRead, util.ScopeTransfersWrite}, arg.Scopes)
+						return db.ApiKey{
+							ID:        1,
+							Owner:     arg.Owner,
+							Name:      arg.Name,
+							Prefix:    arg.Prefix,
+							KeyHash:   arg.KeyHash,
+							Scopes:    arg.Scopes,
+							ExpiredAt: arg.ExpiredAt,
+						}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp createApiKeyResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				prefix, ok := util.ApiKeyPrefix(rsp.Key)
+				require.True(t, ok)
+				require.Equal(t, prefix, rsp.ApiKey.Prefix)
+				require.NotContains(t, recorder.Body.String(), "key_hash")
+			},
+		},
+		{
+			name: "UnknownScope",
+			body: gin.H{
+				"name":       "nightly batch",
+				"scopes":     []string{"accounts:delete"},
+				"expired_at": time.Now().Add(time.Hour),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NoScopes",
+			body: gin.H{
+				"name":       "nightly batch",
+				"scopes":     []string{},
+				"expired_at": time.Now().Add(time.Hour),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiryInThePast",
+			body: gin.H{
+				"name":       "nightly batch",
+				"scopes":     []string{util.ScopeAccountsRead},
+				"expired_at": time.Now().Add(-time.Hour),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiryTooFar",
+			body: gin.H{
+				"name":       "nightly batch",
+				"scopes":     []string{util.ScopeAccountsRead},
+				"expired_at": time.Now().Add(48 * time.Hour),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{
+				"name":       "nightly batch",
+				"scopes":     []string{util.ScopeAccountsRead},
+				"expired_at": time.Now().Add(time.Hour),
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateApiKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthUser(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/api_keys", bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestManageApiKeyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	apiKey, _ := randomApiKey(t, user.Username, util.ScopeAccountsRead)
+
+	testCases := []struct {
+		name          string
+		method        string
+		body          gin.H
+		authUsername  string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "Get",
+			method:       http.MethodGet,
+			authUsername: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(apiKey, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp apiKeyResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, apiKey.Prefix, rsp.Prefix)
+				require.Equal(t, apiKey.Scopes, rsp.Scopes)
+			},
+		},
+		{
+			name:         "GetOtherUsersKey",
+			method:       http.MethodGet,
+			authUsername: "someone_else",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(apiKey, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:         "GetNotFound",
+			method:       http.MethodGet,
+			authUsername: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:         "UpdateScopes",
+			method:       http.MethodPatch,
+			body:         gin.H{"scopes": []string{util.ScopeAccountsRead, util.ScopeAccountsWrite}},
+			authUsername: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					UpdateApiKey(gomock.Any(), gomock.Eq(db.UpdateApiKeyParams{
+						ID:     apiKey.ID,
+						Scopes: []string{util.ScopeAccountsRead, util.ScopeAccountsWrite},
+					})).
+					Times(1).
+					Return(apiKey, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:         "UpdateUnknownScope",
+			method:       http.MethodPatch,
+			body:         gin.H{"scopes": []string{"everything"}},
+			authUsername: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:         "Delete",
+			method:       http.MethodDelete,
+			authUsername: user.Username,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					DeleteApiKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:         "DeleteOtherUsersKey",
+			method:       http.MethodDelete,
+			authUsername: "someone_else",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					DeleteApiKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthUser(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			var body bytes.Buffer
+			if tc.body != nil {
+				require.NoError(t, json.NewEncoder(&body).Encode(tc.body))
+			}
+
+			url := fmt.Sprintf("/api_keys/%d", apiKey.ID)
+			request, err := http.NewRequest(tc.method, url, &body)
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.authUsername, util.DepositorRole, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestApiKeyAuthentication(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		method        string
+		url           string
+		setupAuth     func(t *testing.T, request *http.Request, store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "ScopeGranted",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, store *mockdb.MockStore) {
+				apiKey, key := randomApiKey(t, user.Username, util.ScopeAccountsRead)
+				store.EXPECT().
+					GetApiKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Eq(db.ListAccountsParams{Owner: user.Username, Limit: 5, Offset: 0})).
+					Times(1).
+					Return([]db.Account{}, nil)
+				addApiKeyAuthorization(request, key)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "ScopeMissing",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, store *mockdb.MockStore) {
+				apiKey, key := randomApiKey(t, user.Username, util.ScopeTransfersWrite)
+				store.EXPECT().
+					GetApiKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Any()).
+					Times(0)
+				addApiKeyAuthorization(request, key)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "RouteClosedToApiKeys",
+			method: http.MethodGet,
+			url:    "/api_keys?page_id=1&page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, store *mockdb.MockStore) {
+				apiKey, key := randomApiKey(t, user.Username, util.ScopeAccountsRead, util.ScopeAccountsWrite, util.ScopeTransfersWrite)
+				store.EXPECT().
+					GetApiKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					ListApiKeys(gomock.Any(), gomock.Any()).
+					Times(0)
+				addApiKeyAuthorization(request, key)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "WrongSecret",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, store *mockdb.MockStore) {
+				apiKey, _ := randomApiKey(t, user.Username, util.ScopeAccountsRead)
+				store.EXPECT().
+					GetApiKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				addApiKeyAuthorization(request, apiKey.Prefix+"."+util.RandomString(32))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "UnknownPrefix",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, store *mockdb.MockStore) {
+				_, key := randomApiKey(t, user.Username, util.ScopeAccountsRead)
+				store.EXPECT().
+					GetApiKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, sql.ErrNoRows)
+				addApiKeyAuthorization(request, key)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "MalformedKey",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, store *mockdb.MockStore) {
+				store.EXPECT().
+					GetApiKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(0)
+				addApiKeyAuthorization(request, util.RandomString(32))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "Expired",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, store *mockdb.MockStore) {
+				apiKey, key := randomApiKey(t, user.Username, util.ScopeAccountsRead)
+				apiKey.ExpiredAt = time.Now().Add(-time.Minute)
+				store.EXPECT().
+					GetApiKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				addApiKeyAuthorization(request, key)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "DeactivatedOwner",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			setupAuth: func(t *testing.T, request *http.Request, store *mockdb.MockStore) {
+				owner := util.RandomOwner()
+				apiKey, key := randomApiKey(t, owner, util.ScopeAccountsRead)
+				store.EXPECT().
+					GetApiKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(owner)).
+					Times(1).
+					Return(db.User{Username: owner, DeactivatedAt: sql.NullTime{Time: time.Now(), Valid: true}}, nil)
+				addApiKeyAuthorization(request, key)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, err)
+			tc.setupAuth(t, request, store)
+			stubAuthUser(store)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
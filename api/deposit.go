@@ -0,0 +1,81 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+)
+
+type clearingRequest struct {
+	Amount int64 `json:"amount" binding:"required,gt=0"`
+	//* must be the account's currency, there is no conversion on the way in or out
+	Currency string `json:"currency" binding:"required,currency"`
+}
+
+// ^ the clearing side is the bank's business, customers only see their own account
+type clearingResponse struct {
+	Account db.Account `json:"account"`
+	Entry   db.Entry   `json:"entry"`
+}
+
+func (server *Server) deposit(ctx *gin.Context) {
+	server.clearing(ctx, func(accountID int64, amount int64) (db.ClearingTxResult, error) {
+		return server.store.DepositTx(ctx, db.DepositTxParams{AccountID: accountID, Amount: amount})
+	})
+}
+
+func (server *Server) withdraw(ctx *gin.Context) {
+	server.clearing(ctx, func(accountID int64, amount int64) (db.ClearingTxResult, error) {
+		return server.store.WithdrawTx(ctx, db.WithdrawTxParams{AccountID: accountID, Amount: amount})
+	})
+}
+
+// clearing checks the request and the account the same way for deposits and withdrawals, then runs tx
+func (server *Server) clearing(ctx *gin.Context, tx func(accountID int64, amount int64) (db.ClearingTxResult, error)) {
+
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req clearingRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, valid := server.validAccount(ctx, uri.ID, req.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != util.BankerRole && authPayload.Username != account.Owner {
+		err := errors.New("account does not belong to authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	result, err := tx(account.ID, req.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		case errors.Is(err, db.ErrClearingAccount):
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+		case errors.Is(err, db.ErrInsufficientFunds), errors.Is(err, db.ErrNoClearingAccount):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, clearingResponse{Account: result.Account, Entry: result.Entry})
+}
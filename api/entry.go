@@ -0,0 +1,115 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+)
+
+type listAccountEntriesRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=1,max=10"`
+}
+
+// ^ one line of the account history, the hash chain stays internal
+type accountEntryResponse struct {
+	ID           int64 `json:"id"`
+	Amount       int64 `json:"amount"`
+	BalanceAfter int64 `json:"balance_after"`
+	//* left out for deposits and withdrawals
+	TransferID *int64    `json:"transfer_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newAccountEntryResponse(entry db.Entry) accountEntryResponse {
+
+	rsp := accountEntryResponse{
+		ID:           entry.ID,
+		Amount:       entry.Amount,
+		BalanceAfter: entry.BalanceAfter,
+		CreatedAt:    entry.CreatedAt,
+	}
+
+	if entry.TransferID.Valid {
+		rsp.TransferID = &entry.TransferID.Int64
+	}
+	return rsp
+}
+
+// listAccountEntries is the history of an account, oldest first, for its owner or a banker
+func (server *Server) listAccountEntries(ctx *gin.Context) {
+
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req listAccountEntriesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != util.BankerRole && authPayload.Username != account.Owner {
+		err := errors.New("account does not belong to authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	entries, err := server.store.ListEntries(ctx, db.ListEntriesParams{
+		AccountID: account.ID,
+		Limit:     req.PageSize,
+		Offset:    (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]accountEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		rsp = append(rsp, newAccountEntryResponse(entry))
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// ! banker only, a broken chain is still a 200: the result says where it breaks
+func (server *Server) verifyEntryChain(ctx *gin.Context) {
+
+	var req getAccountRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	result, err := db.VerifyEntryChain(ctx, server.store, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
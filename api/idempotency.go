@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/scheduler"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// ^ a client that retries after a timeout sends the same Idempotency-Key again,
+// ^ the first response is stored with the transfer and replayed instead of moving the money twice
+func newTransferIdempotency(ctx *gin.Context, owner string, req transferRequest) (*db.TransferIdempotency, bool) {
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		return nil, true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		err := fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return nil, false
+	}
+	//* standing orders keep their runs under the owner's keys too, a client must not take their slot
+	if strings.HasPrefix(key, scheduler.IdempotencyKeyPrefix) {
+		err := fmt.Errorf("%s must not start with %q", idempotencyKeyHeader, scheduler.IdempotencyKeyPrefix)
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return nil, false
+	}
+
+	//* hash the parsed request, so whitespace or field order don't count as a different body
+	body, err := json.Marshal(req)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, false
+	}
+	sum := sha256.Sum256(body)
+
+	return &db.TransferIdempotency{
+		Owner:          owner,
+		Key:            key,
+		RequestHash:    hex.EncodeToString(sum[:]),
+		ResponseStatus: http.StatusOK,
+	}, true
+}
+
+// replayIdempotent writes the stored response for the key, it returns false
+// when the key has not been used yet and the request should go ahead
+func (server *Server) replayIdempotent(ctx *gin.Context, idem *db.TransferIdempotency) bool {
+	stored, err := server.store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Owner:          idem.Owner,
+		IdempotencyKey: idem.Key,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return true
+	}
+
+	if stored.RequestHash != idem.RequestHash {
+		err := fmt.Errorf("%s %q was already used with a different request", idempotencyKeyHeader, idem.Key)
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+		return true
+	}
+
+	ctx.Header(idempotentReplayedHeader, "true")
+	ctx.Data(int(stored.ResponseStatus), "application/json; charset=utf-8", stored.ResponseBody)
+	return true
+}
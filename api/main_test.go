@@ -1,6 +1,7 @@
 package api
 
 import (
+	"io"
 	"os"
 	"testing"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	db "github.com/itsadijmbt/simple_bank/db/sqlc"
 	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/mail"
 	"github.com/itsadijmbt/simple_bank/token"
 	_ "github.com/lib/pq"
 	"github.com/stretchr/testify/require"
@@ -23,14 +25,21 @@ func TestMain(m *testing.M) {
 func NewTestServer(t *testing.T, store db.Store) *Server {
 
 	config := util.Config{
-		TokenSymmetricKey:   util.RandomString(32),
-		AccessTokenDuration: time.Minute,
+		TokenSymmetricKey:    util.RandomString(32),
+		AccessTokenDuration:  time.Minute,
+		MFAChallengeDuration: time.Minute,
+		TOTPEncryptionKey:    util.RandomString(32),
+		ApiKeyMaxDuration:    24 * time.Hour,
+		OAuthCodeDuration:    time.Minute,
 	}
 	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
 	require.NoError(t, err)
 	server, err := NewServer(config, store)
 	require.NoError(t, err)
 	server.tokenMaker = tokenMaker
+	//* in-memory only so the mock store does not see revocation lookups
+	server.revocations = token.NewCachedRevocationStore(nil, 0)
+	server.mailer = mail.NewLogSender(io.Discard)
 	server.setupRouter()
 	return server
 
@@ -1,22 +1,37 @@
 package api
 
 import (
+	"crypto/subtle"
+	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
 	"github.com/itsadijmbt/simple_bank/token"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
+	authorizationTypeApiKey = "apikey"
 	authorizationPayloadKey = "authorization_payload"
+	//* the db.User the token belongs to, loaded anyway for the password check
+	authorizationUserKey = "authorization_user"
+	//* the db.ApiKey, only set when the request came in with an api key
+	authorizationApiKeyKey = "authorization_api_key"
 )
 
 // authMiddleware verifies the access token in Authorization header
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// and rejects tokens that were revoked (logout) before they expired
+// or that were issued before the user's last password change
+// or that belong to a deactivated user.
+// Service accounts send "ApiKey <key>" instead of a bearer token.
+func authMiddleware(tokenMaker token.Maker, revocations token.RevocationStore, store db.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// Step 1: Get Authorization header
 		authHeader := ctx.GetHeader(authorizationHeaderKey)
@@ -35,28 +50,246 @@ func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
 			return
 		}
 
+		// Step 3: Verify the token or the api key and load its user
+		var (
+			payload *token.Payload
+			user    db.User
+			ok      bool
+		)
+
 		authType := strings.ToLower(fields[0])
-		if authType != authorizationTypeBearer {
+		switch authType {
+		case authorizationTypeBearer:
+			payload, user, ok = verifyBearerToken(ctx, tokenMaker, revocations, store, fields[1])
+		case authorizationTypeApiKey:
+			payload, user, ok = verifyApiKey(ctx, store, fields[1])
+		default:
 			err := errors.New("unsupported authorization type " + authType)
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
 			return
 		}
+		if !ok {
+			return
+		}
 
-		// Step 3: Extract the token part
-		accessToken := fields[1]
-
-		// Step 4: Verify token using tokenMaker
-		payload, err := tokenMaker.VerifyToken(accessToken)
-		if err != nil {
-			err := errors.New("invalid auth token")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		if user.DeactivatedAt.Valid {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errUserDeactivated))
 			return
 		}
 
-		// Step 5: Store payload in context so downstream handlers can use it
+		//* the role in a token is the one it had at login, a demoted banker
+		//* must lose access straight away so the stored role wins
+		payload.Role = user.Role
+
+		// Step 4: Store payload in context so downstream handlers can use it
 		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Set(authorizationUserKey, user)
 
 		// Continue to next handler
 		ctx.Next()
 	}
 }
+
+func verifyBearerToken(
+	ctx *gin.Context,
+	tokenMaker token.Maker,
+	revocations token.RevocationStore,
+	store db.Store,
+	accessToken string,
+) (*token.Payload, db.User, bool) {
+
+	payload, err := tokenMaker.VerifyToken(accessToken)
+	if err != nil {
+		err := errors.New("invalid auth token")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return nil, db.User{}, false
+	}
+
+	//* a refresh token only renews access tokens, it never opens a route
+	if payload.TokenType != token.TokenTypeAccess {
+		err := errors.New("token is not an access token")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return nil, db.User{}, false
+	}
+
+	//* an mfa challenge is not a login yet
+	if payload.Role == util.MFAChallengeRole {
+		err := errors.New("two-factor authentication is not complete")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return nil, db.User{}, false
+	}
+
+	// make sure the token was not revoked by a logout
+	revoked, err := revocations.IsRevoked(ctx, payload)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, db.User{}, false
+	}
+	if revoked {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(token.ErrRevokedToken))
+		return nil, db.User{}, false
+	}
+
+	user, ok := loadAuthUser(ctx, store, payload.Username)
+	if !ok {
+		return nil, db.User{}, false
+	}
+
+	// tokens issued before a password change are no longer valid
+	if err := payload.ValidSince(user.PasswordChangedAt); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return nil, db.User{}, false
+	}
+
+	// oauth tokens die with the client they were issued to
+	if payload.ClientID != "" {
+		if _, err := store.GetClient(ctx, payload.ClientID); err != nil {
+			if err == sql.ErrNoRows {
+				err := errors.New("oauth client no longer exists")
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+				return nil, db.User{}, false
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return nil, db.User{}, false
+		}
+	}
+
+	return payload, user, true
+}
+
+var errInvalidApiKey = errors.New("invalid api key")
+
+// ! api keys are long lived and outlive password changes,
+// ! they are cut off by deleting them or deactivating the owner
+func verifyApiKey(ctx *gin.Context, store db.Store, key string) (*token.Payload, db.User, bool) {
+
+	prefix, ok := util.ApiKeyPrefix(key)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errInvalidApiKey))
+		return nil, db.User{}, false
+	}
+
+	apiKey, err := store.GetApiKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errInvalidApiKey))
+			return nil, db.User{}, false
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, db.User{}, false
+	}
+
+	keyHash := util.HashSecretCode(key)
+	if subtle.ConstantTimeCompare([]byte(keyHash), []byte(apiKey.KeyHash)) != 1 {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errInvalidApiKey))
+		return nil, db.User{}, false
+	}
+
+	if time.Now().After(apiKey.ExpiredAt) {
+		err := errors.New("api key has expired")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		return nil, db.User{}, false
+	}
+
+	user, ok := loadAuthUser(ctx, store, apiKey.Owner)
+	if !ok {
+		return nil, db.User{}, false
+	}
+
+	payload, err := token.NewPayload(user.Username, user.Role, token.TokenTypeAccess, time.Until(apiKey.ExpiredAt))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return nil, db.User{}, false
+	}
+	payload.Scopes = apiKey.Scopes
+
+	ctx.Set(authorizationApiKeyKey, apiKey)
+	return payload, user, true
+}
+
+func loadAuthUser(ctx *gin.Context, store db.Store, username string) (db.User, bool) {
+	user, err := store.GetUser(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			return db.User{}, false
+		}
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return db.User{}, false
+	}
+	return user, true
+}
+
+// * api keys and oauth tokens only carry the scopes they were granted
+func isScopedCredential(ctx *gin.Context) bool {
+	if _, isApiKey := ctx.Get(authorizationApiKeyKey); isApiKey {
+		return true
+	}
+	payload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	return payload.ClientID != ""
+}
+
+// requireScope runs after authMiddleware, api keys and oauth tokens need
+// the scope while a logged in user is only limited by their role
+func requireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !isScopedCredential(ctx) {
+			ctx.Next()
+			return
+		}
+
+		payload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if !payload.HasScope(scope) {
+			err := fmt.Errorf("credential is missing the %q scope", scope)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// rejectScopedCredentials runs after authMiddleware on routes that need a real
+// login, like managing api keys and oauth clients or changing the password
+func rejectScopedCredentials() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if isScopedCredential(ctx) {
+			err := errors.New("route is not available to api keys or oauth tokens")
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// authorizeRoles runs after authMiddleware on a single route
+// and only lets through users whose current role is in the allowed list
+func authorizeRoles(allowedRoles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := ctx.MustGet(authorizationUserKey).(db.User)
+
+		for _, role := range allowedRoles {
+			if user.Role == role {
+				ctx.Next()
+				return
+			}
+		}
+
+		err := fmt.Errorf("role %q is not allowed to access this route", user.Role)
+		ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+	}
+}
+
+var errEmailNotVerified = errors.New("email address is not verified")
+
+// requireVerifiedEmail runs after authMiddleware on routes that move or hold money
+func requireVerifiedEmail() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := ctx.MustGet(authorizationUserKey).(db.User)
+
+		if !user.IsEmailVerified {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(errEmailNotVerified))
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,521 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+)
+
+// ^ error codes from RFC 6749 section 5.2
+const (
+	oauthErrInvalidRequest       = "invalid_request"
+	oauthErrInvalidClient        = "invalid_client"
+	oauthErrInvalidGrant         = "invalid_grant"
+	oauthErrInvalidScope         = "invalid_scope"
+	oauthErrUnauthorizedClient   = "unauthorized_client"
+	oauthErrUnsupportedGrantType = "unsupported_grant_type"
+	oauthErrAccessDenied         = "access_denied"
+	oauthErrServerError          = "server_error"
+)
+
+const (
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeClientCredentials = "client_credentials"
+)
+
+// * same "error" key as errorResponse, plus the description the rfc asks for
+func oauthError(ctx *gin.Context, status int, code string, err error) {
+	rsp := gin.H{"error": code}
+	if err != nil {
+		rsp["error_description"] = err.Error()
+	}
+	ctx.AbortWithStatusJSON(status, rsp)
+}
+
+// ^ the parameters of the authorization request (RFC 6749 4.1.1, RFC 7636 4.3),
+// ^ only the S256 PKCE method is accepted
+type authorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required,eq=code"`
+	ClientID            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri" binding:"required"`
+	Scope               string `form:"scope" json:"scope" binding:"required"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge" binding:"required,len=43"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" binding:"required,eq=S256"`
+}
+
+// ^ what the consent screen shows the user
+type consentResponse struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	Scopes      []string `json:"scopes"`
+	RedirectURI string   `json:"redirect_uri"`
+	State       string   `json:"state,omitempty"`
+}
+
+// ! step one of the code flow: the logged in user is asked what the client wants
+func (server *Server) getAuthorize(ctx *gin.Context) {
+
+	var req authorizeRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, err)
+		return
+	}
+
+	client, scopes, ok := server.validAuthorizeRequest(ctx, req)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, consentResponse{
+		ClientID:    client.ID,
+		ClientName:  client.Name,
+		Scopes:      scopes,
+		RedirectURI: req.RedirectURI,
+		State:       req.State,
+	})
+}
+
+type consentRequest struct {
+	authorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// ^ the consent page follows redirect_to, a redirect response would be
+// ^ swallowed by the fetch that carries the bearer token
+type consentResultResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// ! step two: the user's answer, an approval turns into a single use code
+func (server *Server) postAuthorize(ctx *gin.Context) {
+
+	var req consentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, err)
+		return
+	}
+
+	client, scopes, ok := server.validAuthorizeRequest(ctx, req.authorizeRequest)
+	if !ok {
+		return
+	}
+
+	//* from here on errors go back to the client through the redirect uri
+	redirect, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, err)
+		return
+	}
+	query := redirect.Query()
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+
+	if !req.Approve {
+		query.Set("error", oauthErrAccessDenied)
+		redirect.RawQuery = query.Encode()
+		ctx.JSON(http.StatusOK, consentResultResponse{RedirectTo: redirect.String()})
+		return
+	}
+
+	code, err := util.NewSecretCode()
+	if err != nil {
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	_, err = server.store.CreateOauthCode(ctx, db.CreateOauthCodeParams{
+		CodeHash:      util.HashSecretCode(code),
+		ClientID:      client.ID,
+		Username:      authPayload.Username,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiredAt:     time.Now().Add(server.config.OAuthCodeDuration),
+	})
+	if err != nil {
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return
+	}
+
+	query.Set("code", code)
+	redirect.RawQuery = query.Encode()
+	ctx.JSON(http.StatusOK, consentResultResponse{RedirectTo: redirect.String()})
+}
+
+// * checks the client, its redirect uri and the scopes it asks for
+func (server *Server) validAuthorizeRequest(ctx *gin.Context, req authorizeRequest) (db.Client, []string, bool) {
+
+	client, err := server.store.GetClient(ctx, req.ClientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			oauthError(ctx, http.StatusBadRequest, oauthErrInvalidClient, errors.New("unknown client"))
+			return client, nil, false
+		}
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return client, nil, false
+	}
+
+	//* exact match only, a loose match would let codes leak to other uris
+	if !containsString(client.RedirectUris, req.RedirectURI) {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, errors.New("redirect_uri is not registered for this client"))
+		return client, nil, false
+	}
+
+	scopes, err := parseScopes(req.Scope, client.Scopes)
+	if err != nil {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidScope, err)
+		return client, nil, false
+	}
+
+	return client, scopes, true
+}
+
+// * scope is space separated (RFC 6749 3.3), every entry must be allowed for the client
+func parseScopes(scope string, allowed []string) ([]string, error) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !containsString(allowed, s) {
+			return nil, fmt.Errorf("scope %q is not allowed for this client", s)
+		}
+		if !containsString(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("no scope requested")
+	}
+	return scopes, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ^ token endpoint parameters, form encoded as RFC 6749 requires
+type tokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+func (server *Server) oauthToken(ctx *gin.Context) {
+
+	var req tokenRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, err)
+		return
+	}
+
+	client, ok := server.authenticateClient(ctx)
+	if !ok {
+		return
+	}
+
+	var (
+		user   db.User
+		scopes []string
+	)
+
+	switch req.GrantType {
+	case grantTypeAuthorizationCode:
+		user, scopes, ok = server.redeemAuthorizationCode(ctx, client, req)
+	case grantTypeClientCredentials:
+		user, scopes, ok = server.clientCredentialsGrant(ctx, client, req)
+	default:
+		oauthError(ctx, http.StatusBadRequest, oauthErrUnsupportedGrantType, nil)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	payload, err := token.NewPayload(user.Username, user.Role, token.TokenTypeAccess, server.config.AccessTokenDuration)
+	if err != nil {
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return
+	}
+	payload.Scopes = scopes
+	payload.ClientID = client.ID
+
+	accessToken, err := server.tokenMaker.SignPayload(payload)
+	if err != nil {
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+	ctx.JSON(http.StatusOK, oauthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(server.config.AccessTokenDuration.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+func (server *Server) redeemAuthorizationCode(ctx *gin.Context, client db.Client, req tokenRequest) (db.User, []string, bool) {
+
+	if req.Code == "" || req.RedirectURI == "" || req.CodeVerifier == "" {
+		err := errors.New("code, redirect_uri and code_verifier are required")
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, err)
+		return db.User{}, nil, false
+	}
+
+	//* used up before the checks below, a code that was tried once is gone
+	code, err := server.store.UseOauthCode(ctx, util.HashSecretCode(req.Code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			oauthError(ctx, http.StatusBadRequest, oauthErrInvalidGrant, errors.New("code is invalid, expired or already used"))
+			return db.User{}, nil, false
+		}
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return db.User{}, nil, false
+	}
+
+	if code.ClientID != client.ID || code.RedirectUri != req.RedirectURI {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidGrant, errors.New("code was issued for another client or redirect_uri"))
+		return db.User{}, nil, false
+	}
+
+	challenge := util.PKCEChallenge(req.CodeVerifier)
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidGrant, errors.New("code_verifier does not match"))
+		return db.User{}, nil, false
+	}
+
+	user, ok := server.oauthSubject(ctx, code.Username)
+	return user, code.Scopes, ok
+}
+
+// ! the client acts for itself, that is for the user who registered it
+func (server *Server) clientCredentialsGrant(ctx *gin.Context, client db.Client, req tokenRequest) (db.User, []string, bool) {
+
+	if client.SecretHash == "" {
+		oauthError(ctx, http.StatusBadRequest, oauthErrUnauthorizedClient, errors.New("public clients cannot use client_credentials"))
+		return db.User{}, nil, false
+	}
+
+	scopes := client.Scopes
+	if req.Scope != "" {
+		var err error
+		if scopes, err = parseScopes(req.Scope, client.Scopes); err != nil {
+			oauthError(ctx, http.StatusBadRequest, oauthErrInvalidScope, err)
+			return db.User{}, nil, false
+		}
+	}
+
+	user, ok := server.oauthSubject(ctx, client.Owner)
+	return user, scopes, ok
+}
+
+func (server *Server) oauthSubject(ctx *gin.Context, username string) (db.User, bool) {
+	user, err := server.store.GetUser(ctx, username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			oauthError(ctx, http.StatusBadRequest, oauthErrInvalidGrant, err)
+			return user, false
+		}
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return user, false
+	}
+
+	if user.DeactivatedAt.Valid {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidGrant, errUserDeactivated)
+		return user, false
+	}
+	return user, true
+}
+
+// * clients authenticate with http basic auth or with client_id and
+// * client_secret in the form (RFC 6749 2.3.1), public clients send no secret
+func (server *Server) authenticateClient(ctx *gin.Context) (db.Client, bool) {
+
+	clientID, secret, usedBasic := ctx.Request.BasicAuth()
+	if !usedBasic {
+		clientID = ctx.PostForm("client_id")
+		secret = ctx.PostForm("client_secret")
+	}
+
+	reject := func(err error) (db.Client, bool) {
+		if usedBasic {
+			ctx.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		oauthError(ctx, http.StatusUnauthorized, oauthErrInvalidClient, err)
+		return db.Client{}, false
+	}
+
+	if clientID == "" {
+		return reject(errors.New("client authentication is missing"))
+	}
+
+	client, err := server.store.GetClient(ctx, clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return reject(errors.New("unknown client"))
+		}
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return db.Client{}, false
+	}
+
+	if client.SecretHash == "" {
+		if secret != "" {
+			return reject(errors.New("public clients have no secret"))
+		}
+	} else {
+		secretHash := util.HashSecretCode(secret)
+		if subtle.ConstantTimeCompare([]byte(secretHash), []byte(client.SecretHash)) != 1 {
+			return reject(errors.New("invalid client secret"))
+		}
+	}
+
+	//* the ip limit runs as middleware, the client is only known here
+	if ok, retryAfter := server.oauthLimiter.allow("client:" + client.ID); !ok {
+		abortTooManyRequests(ctx, retryAfter, errRateLimited)
+		return db.Client{}, false
+	}
+	return client, true
+}
+
+type tokenIntrospectionRequest struct {
+	Token string `form:"token" binding:"required"`
+}
+
+// ^ RFC 7662 response, only active is set for tokens that are not
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// ! token introspection (RFC 7662), a client only learns about its own tokens
+func (server *Server) introspectToken(ctx *gin.Context) {
+
+	var req tokenIntrospectionRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, err)
+		return
+	}
+
+	client, ok := server.authenticateClient(ctx)
+	if !ok {
+		return
+	}
+	if client.SecretHash == "" {
+		oauthError(ctx, http.StatusUnauthorized, oauthErrInvalidClient, errors.New("public clients cannot introspect tokens"))
+		return
+	}
+
+	payload, active, err := server.activeClientToken(ctx, client, req.Token)
+	if err != nil {
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return
+	}
+	if !active {
+		ctx.JSON(http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, introspectionResponse{
+		Active:    true,
+		Scope:     strings.Join(payload.Scopes, " "),
+		ClientID:  payload.ClientID,
+		Username:  payload.Username,
+		TokenType: "Bearer",
+		Exp:       payload.ExpiredAt.Unix(),
+		Iat:       payload.IssuedAt.Unix(),
+		Sub:       payload.Username,
+		Jti:       payload.Id.String(),
+	})
+}
+
+// ! token revocation (RFC 7009), unknown or foreign tokens still get a 200
+func (server *Server) revokeToken(ctx *gin.Context) {
+
+	var req tokenIntrospectionRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		oauthError(ctx, http.StatusBadRequest, oauthErrInvalidRequest, err)
+		return
+	}
+
+	client, ok := server.authenticateClient(ctx)
+	if !ok {
+		return
+	}
+
+	payload, active, err := server.activeClientToken(ctx, client, req.Token)
+	if err != nil {
+		oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+		return
+	}
+
+	if active {
+		if err := server.revocations.Revoke(ctx, payload); err != nil {
+			oauthError(ctx, http.StatusInternalServerError, oauthErrServerError, err)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// * an active token verifies, was issued to this client, is not revoked
+// * and its user can still log in
+func (server *Server) activeClientToken(ctx *gin.Context, client db.Client, accessToken string) (*token.Payload, bool, error) {
+
+	payload, err := server.tokenMaker.VerifyToken(accessToken)
+	if err != nil || payload.TokenType != token.TokenTypeAccess || payload.ClientID != client.ID {
+		return nil, false, nil
+	}
+
+	revoked, err := server.revocations.IsRevoked(ctx, payload)
+	if err != nil || revoked {
+		return nil, false, err
+	}
+
+	user, err := server.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if user.DeactivatedAt.Valid || payload.ValidSince(user.PasswordChangedAt) != nil {
+		return nil, false, nil
+	}
+
+	return payload, true, nil
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+)
+
+type clientResponse struct {
+	ID           string    `json:"id"`
+	Owner        string    `json:"owner"`
+	Name         string    `json:"name"`
+	Confidential bool      `json:"confidential"`
+	RedirectUris []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newClientResponse(client db.Client) clientResponse {
+	return clientResponse{
+		ID:           client.ID,
+		Owner:        client.Owner,
+		Name:         client.Name,
+		Confidential: client.SecretHash != "",
+		RedirectUris: client.RedirectUris,
+		Scopes:       client.Scopes,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// ^ public clients (mobile and browser apps) cannot keep a secret,
+// ^ they only get the authorization code flow and rely on PKCE alone
+type createClientRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectUris []string `json:"redirect_uris" binding:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes" binding:"required,min=1,dive,scope"`
+	Confidential bool     `json:"confidential"`
+}
+
+// ! the secret is only returned here, the database keeps a hash of it
+type createClientResponse struct {
+	ClientSecret string         `json:"client_secret,omitempty"`
+	Client       clientResponse `json:"client"`
+}
+
+func (server *Server) createClient(ctx *gin.Context) {
+
+	var req createClientRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	clientID, err := util.NewClientID()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var secret, secretHash string
+	if req.Confidential {
+		if secret, err = util.NewSecretCode(); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		secretHash = util.HashSecretCode(secret)
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	client, err := server.store.CreateClient(ctx, db.CreateClientParams{
+		ID:           clientID,
+		Owner:        authPayload.Username,
+		Name:         req.Name,
+		SecretHash:   secretHash,
+		RedirectUris: req.RedirectUris,
+		Scopes:       req.Scopes,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, createClientResponse{
+		ClientSecret: secret,
+		Client:       newClientResponse(client),
+	})
+}
+
+type clientUriRequest struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+// * the auth middleware looks the client up for every oauth token,
+// * so deleting it cuts off the tokens it was issued as well
+func (server *Server) deleteClient(ctx *gin.Context) {
+
+	var uri clientUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	client, err := server.store.GetClient(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if client.Owner != authPayload.Username {
+		ctx.JSON(http.StatusNotFound, errorResponse(sql.ErrNoRows))
+		return
+	}
+
+	if err := server.store.DeleteClient(ctx, client.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
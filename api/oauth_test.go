@@ -0,0 +1,593 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mockdb "github.com/itsadijmbt/simple_bank/db/mock"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testRedirectURI = "https://partner.example.com/callback"
+
+// ^ oauthTestStore keeps clients and codes in memory so a whole flow
+// ^ can run against the mock store without scripting every call
+type oauthTestStore struct {
+	mu      sync.Mutex
+	user    db.User
+	clients map[string]db.Client
+	codes   map[string]db.OauthCode
+}
+
+func newOAuthTestStore(t *testing.T, store *mockdb.MockStore) *oauthTestStore {
+	user, _ := randomUser(t)
+	user.IsEmailVerified = true
+
+	fake := &oauthTestStore{
+		user:    user,
+		clients: make(map[string]db.Client),
+		codes:   make(map[string]db.OauthCode),
+	}
+
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, username string) (db.User, error) {
+			if username != fake.user.Username {
+				return db.User{Username: username, Role: util.DepositorRole}, nil
+			}
+			return fake.user, nil
+		})
+	store.EXPECT().
+		CreateClient(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, arg db.CreateClientParams) (db.Client, error) {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			client := db.Client{
+				ID:           arg.ID,
+				Owner:        arg.Owner,
+				Name:         arg.Name,
+				SecretHash:   arg.SecretHash,
+				RedirectUris: arg.RedirectUris,
+				Scopes:       arg.Scopes,
+				CreatedAt:    time.Now(),
+			}
+			fake.clients[client.ID] = client
+			return client, nil
+		})
+	store.EXPECT().
+		GetClient(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, id string) (db.Client, error) {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			client, ok := fake.clients[id]
+			if !ok {
+				return db.Client{}, sql.ErrNoRows
+			}
+			return client, nil
+		})
+	store.EXPECT().
+		CreateOauthCode(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, arg db.CreateOauthCodeParams) (db.OauthCode, error) {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			code := db.OauthCode{
+				CodeHash:      arg.CodeHash,
+				ClientID:      arg.ClientID,
+				Username:      arg.Username,
+				RedirectUri:   arg.RedirectUri,
+				Scopes:        arg.Scopes,
+				CodeChallenge: arg.CodeChallenge,
+				CreatedAt:     time.Now(),
+				ExpiredAt:     arg.ExpiredAt,
+			}
+			fake.codes[code.CodeHash] = code
+			return code, nil
+		})
+	store.EXPECT().
+		UseOauthCode(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, codeHash string) (db.OauthCode, error) {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			code, ok := fake.codes[codeHash]
+			if !ok || code.IsUsed || time.Now().After(code.ExpiredAt) {
+				return db.OauthCode{}, sql.ErrNoRows
+			}
+			code.IsUsed = true
+			fake.codes[codeHash] = code
+			return code, nil
+		})
+	store.EXPECT().
+		ListAccounts(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return([]db.Account{}, nil)
+
+	return fake
+}
+
+// * registers a client the way a developer would, through the api
+func registerTestClient(t *testing.T, server *Server, owner string, confidential bool, scopes ...string) (clientID string, secret string) {
+	data, err := json.Marshal(gin.H{
+		"name":          "partner app",
+		"redirect_uris": []string{testRedirectURI},
+		"scopes":        scopes,
+		"confidential":  confidential,
+	})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/oauth/clients", bytes.NewReader(data))
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, owner, util.DepositorRole, time.Minute)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp createClientResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, confidential, rsp.ClientSecret != "")
+	return rsp.Client.ID, rsp.ClientSecret
+}
+
+func authorizeQuery(clientID string, scope string, challenge string) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {scope},
+		"state":                 {"xyz"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+}
+
+// * runs the consent step and returns the query of the uri the user is sent back to
+func consent(t *testing.T, server *Server, username string, query url.Values, approve bool) url.Values {
+	body := gin.H{"approve": approve}
+	for key := range query {
+		body[key] = query.Get(key)
+	}
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/oauth/authorize", bytes.NewReader(data))
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, username, util.DepositorRole, time.Minute)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp consentResultResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	redirect, err := url.Parse(rsp.RedirectTo)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(rsp.RedirectTo, testRedirectURI+"?"))
+	return redirect.Query()
+}
+
+func postOAuthForm(t *testing.T, server *Server, path string, form url.Values, clientID string, secret string) *httptest.ResponseRecorder {
+	request, err := http.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if secret != "" {
+		request.SetBasicAuth(clientID, secret)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func requireOAuthError(t *testing.T, recorder *httptest.ResponseRecorder, status int, code string) {
+	require.Equal(t, status, recorder.Code)
+
+	var rsp map[string]string
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, code, rsp["error"])
+}
+
+func introspect(t *testing.T, server *Server, accessToken string, clientID string, secret string) introspectionResponse {
+	recorder := postOAuthForm(t, server, "/oauth/introspect", url.Values{"token": {accessToken}}, clientID, secret)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp introspectionResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	return rsp
+}
+
+func getWithBearer(t *testing.T, server *Server, path string, accessToken string) *httptest.ResponseRecorder {
+	request, err := http.NewRequest(http.MethodGet, path, nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, "Bearer "+accessToken)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	fake := newOAuthTestStore(t, store)
+	server := NewTestServer(t, store)
+
+	clientID, secret := registerTestClient(t, server, fake.user.Username, true, util.ScopeAccountsRead, util.ScopeTransfersWrite)
+
+	verifier := util.RandomString(64)
+	query := authorizeQuery(clientID, util.ScopeAccountsRead, util.PKCEChallenge(verifier))
+
+	// the consent screen
+	request, err := http.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, fake.user.Username, util.DepositorRole, time.Minute)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var prompt consentResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &prompt))
+	require.Equal(t, "partner app", prompt.ClientName)
+	require.Equal(t, []string{util.ScopeAccountsRead}, prompt.Scopes)
+
+	// the user approves
+	callback := consent(t, server, fake.user.Username, query, true)
+	require.Equal(t, "xyz", callback.Get("state"))
+	code := callback.Get("code")
+	require.NotEmpty(t, code)
+
+	// the client swaps the code for a token
+	form := url.Values{
+		"grant_type":    {grantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {verifier},
+	}
+	recorder = postOAuthForm(t, server, "/oauth/token", form, clientID, secret)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+	var tokenRsp oauthTokenResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &tokenRsp))
+	require.Equal(t, "Bearer", tokenRsp.TokenType)
+	require.Equal(t, util.ScopeAccountsRead, tokenRsp.Scope)
+	require.Equal(t, int64(time.Minute.Seconds()), tokenRsp.ExpiresIn)
+
+	// a code works once
+	recorder = postOAuthForm(t, server, "/oauth/token", form, clientID, secret)
+	requireOAuthError(t, recorder, http.StatusBadRequest, oauthErrInvalidGrant)
+
+	// the token opens what its scopes allow and nothing else
+	recorder = getWithBearer(t, server, "/accounts?page_id=1&page_size=5", tokenRsp.AccessToken)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	transfer, err := http.NewRequest(http.MethodPost, "/transfers", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	transfer.Header.Set(authorizationHeaderKey, "Bearer "+tokenRsp.AccessToken)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, transfer)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	recorder = getWithBearer(t, server, "/api_keys?page_id=1&page_size=5", tokenRsp.AccessToken)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// introspection
+	info := introspect(t, server, tokenRsp.AccessToken, clientID, secret)
+	require.True(t, info.Active)
+	require.Equal(t, clientID, info.ClientID)
+	require.Equal(t, fake.user.Username, info.Username)
+	require.Equal(t, util.ScopeAccountsRead, info.Scope)
+
+	// revocation
+	recorder = postOAuthForm(t, server, "/oauth/revoke", url.Values{"token": {tokenRsp.AccessToken}}, clientID, secret)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	require.False(t, introspect(t, server, tokenRsp.AccessToken, clientID, secret).Active)
+
+	recorder = getWithBearer(t, server, "/accounts?page_id=1&page_size=5", tokenRsp.AccessToken)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestOAuthPublicClientFlow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	fake := newOAuthTestStore(t, store)
+	server := NewTestServer(t, store)
+
+	clientID, _ := registerTestClient(t, server, fake.user.Username, false, util.ScopeAccountsRead)
+
+	verifier := util.RandomString(64)
+	query := authorizeQuery(clientID, util.ScopeAccountsRead, util.PKCEChallenge(verifier))
+	code := consent(t, server, fake.user.Username, query, true).Get("code")
+
+	form := url.Values{
+		"grant_type":   {grantTypeAuthorizationCode},
+		"client_id":    {clientID},
+		"code":         {code},
+		"redirect_uri": {testRedirectURI},
+	}
+
+	// without the verifier a stolen code is useless
+	form.Set("code_verifier", util.RandomString(64))
+	recorder := postOAuthForm(t, server, "/oauth/token", form, "", "")
+	requireOAuthError(t, recorder, http.StatusBadRequest, oauthErrInvalidGrant)
+
+	// and the failed try burnt the code
+	form.Set("code_verifier", verifier)
+	recorder = postOAuthForm(t, server, "/oauth/token", form, "", "")
+	requireOAuthError(t, recorder, http.StatusBadRequest, oauthErrInvalidGrant)
+
+	code = consent(t, server, fake.user.Username, query, true).Get("code")
+	form.Set("code", code)
+	recorder = postOAuthForm(t, server, "/oauth/token", form, "", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// public clients cannot use client credentials or introspect
+	recorder = postOAuthForm(t, server, "/oauth/token", url.Values{
+		"grant_type": {grantTypeClientCredentials},
+		"client_id":  {clientID},
+	}, "", "")
+	requireOAuthError(t, recorder, http.StatusBadRequest, oauthErrUnauthorizedClient)
+
+	recorder = postOAuthForm(t, server, "/oauth/introspect", url.Values{
+		"token":     {"anything"},
+		"client_id": {clientID},
+	}, "", "")
+	requireOAuthError(t, recorder, http.StatusUnauthorized, oauthErrInvalidClient)
+}
+
+func TestOAuthClientCredentialsFlow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	fake := newOAuthTestStore(t, store)
+	server := NewTestServer(t, store)
+
+	clientID, secret := registerTestClient(t, server, fake.user.Username, true, util.ScopeAccountsRead, util.ScopeTransfersWrite)
+
+	// without a scope the client gets everything it registered for
+	recorder := postOAuthForm(t, server, "/oauth/token", url.Values{"grant_type": {grantTypeClientCredentials}}, clientID, secret)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var tokenRsp oauthTokenResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &tokenRsp))
+	require.Equal(t, util.ScopeAccountsRead+" "+util.ScopeTransfersWrite, tokenRsp.Scope)
+
+	info := introspect(t, server, tokenRsp.AccessToken, clientID, secret)
+	require.True(t, info.Active)
+	require.Equal(t, fake.user.Username, info.Sub)
+
+	// a narrower scope can be asked for, a wider one cannot
+	recorder = postOAuthForm(t, server, "/oauth/token", url.Values{
+		"grant_type": {grantTypeClientCredentials},
+		"scope":      {util.ScopeAccountsRead},
+	}, clientID, secret)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = postOAuthForm(t, server, "/oauth/token", url.Values{
+		"grant_type": {grantTypeClientCredentials},
+		"scope":      {util.ScopeAccountsWrite},
+	}, clientID, secret)
+	requireOAuthError(t, recorder, http.StatusBadRequest, oauthErrInvalidScope)
+
+	// a wrong secret
+	recorder = postOAuthForm(t, server, "/oauth/token", url.Values{"grant_type": {grantTypeClientCredentials}}, clientID, "wrong")
+	requireOAuthError(t, recorder, http.StatusUnauthorized, oauthErrInvalidClient)
+	require.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+
+	recorder = postOAuthForm(t, server, "/oauth/token", url.Values{"grant_type": {"password"}}, clientID, secret)
+	requireOAuthError(t, recorder, http.StatusBadRequest, oauthErrUnsupportedGrantType)
+
+	// another client cannot look into or revoke this client's tokens
+	otherID, otherSecret := registerTestClient(t, server, fake.user.Username, true, util.ScopeAccountsRead)
+	require.False(t, introspect(t, server, tokenRsp.AccessToken, otherID, otherSecret).Active)
+
+	recorder = postOAuthForm(t, server, "/oauth/revoke", url.Values{"token": {tokenRsp.AccessToken}}, otherID, otherSecret)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.True(t, introspect(t, server, tokenRsp.AccessToken, clientID, secret).Active)
+
+	// a login token is not an oauth token
+	loginToken, _, err := server.tokenMaker.CreateToken(fake.user.Username, util.DepositorRole, token.TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+	require.False(t, introspect(t, server, loginToken, clientID, secret).Active)
+}
+
+func TestOAuthAuthorizeErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	fake := newOAuthTestStore(t, store)
+	server := NewTestServer(t, store)
+
+	clientID, _ := registerTestClient(t, server, fake.user.Username, false, util.ScopeAccountsRead)
+	challenge := util.PKCEChallenge(util.RandomString(64))
+
+	testCases := []struct {
+		name   string
+		modify func(query url.Values)
+		status int
+		code   string
+	}{
+		{
+			name:   "UnknownClient",
+			modify: func(query url.Values) { query.Set("client_id", "sbc_unknown") },
+			status: http.StatusBadRequest,
+			code:   oauthErrInvalidClient,
+		},
+		{
+			name:   "UnregisteredRedirect",
+			modify: func(query url.Values) { query.Set("redirect_uri", "https://evil.example.com/callback") },
+			status: http.StatusBadRequest,
+			code:   oauthErrInvalidRequest,
+		},
+		{
+			name:   "ScopeNotAllowed",
+			modify: func(query url.Values) { query.Set("scope", util.ScopeTransfersWrite) },
+			status: http.StatusBadRequest,
+			code:   oauthErrInvalidScope,
+		},
+		{
+			name:   "PlainPKCE",
+			modify: func(query url.Values) { query.Set("code_challenge_method", "plain") },
+			status: http.StatusBadRequest,
+			code:   oauthErrInvalidRequest,
+		},
+		{
+			name:   "MissingChallenge",
+			modify: func(query url.Values) { query.Del("code_challenge") },
+			status: http.StatusBadRequest,
+			code:   oauthErrInvalidRequest,
+		},
+		{
+			name:   "TokenResponseType",
+			modify: func(query url.Values) { query.Set("response_type", "token") },
+			status: http.StatusBadRequest,
+			code:   oauthErrInvalidRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			query := authorizeQuery(clientID, util.ScopeAccountsRead, challenge)
+			tc.modify(query)
+
+			request, err := http.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, fake.user.Username, util.DepositorRole, time.Minute)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			requireOAuthError(t, recorder, tc.status, tc.code)
+		})
+	}
+
+	t.Run("Denied", func(t *testing.T) {
+		callback := consent(t, server, fake.user.Username, authorizeQuery(clientID, util.ScopeAccountsRead, challenge), false)
+		require.Equal(t, oauthErrAccessDenied, callback.Get("error"))
+		require.Equal(t, "xyz", callback.Get("state"))
+		require.Empty(t, callback.Get("code"))
+	})
+
+	t.Run("NotLoggedIn", func(t *testing.T) {
+		query := authorizeQuery(clientID, util.ScopeAccountsRead, challenge)
+		request, err := http.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+}
+
+func TestOAuthRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	fake := newOAuthTestStore(t, store)
+	server := NewTestServer(t, store)
+	server.loginLimiter = newRateLimiter(1, time.Minute)
+	server.oauthLimiter = newRateLimiter(2, time.Minute)
+	server.setupRouter()
+
+	clientID, secret := registerTestClient(t, server, fake.user.Username, true, util.ScopeAccountsRead)
+	otherID, otherSecret := registerTestClient(t, server, fake.user.Username, true, util.ScopeAccountsRead)
+
+	introspectFrom := func(ip string, clientID string, secret string) int {
+		form := url.Values{"token": {"not-a-token"}}
+		request, err := http.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth(clientID, secret)
+		request.RemoteAddr = ip + ":1234"
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	//* a used up login limit does not touch the oauth endpoints
+	ok, _ := server.loginLimiter.allow("ip:10.0.0.1")
+	require.True(t, ok)
+	require.Equal(t, http.StatusOK, introspectFrom("10.0.0.1", clientID, secret))
+
+	//* the same client from another ip still hits the client limit
+	require.Equal(t, http.StatusOK, introspectFrom("10.0.0.2", clientID, secret))
+	require.Equal(t, http.StatusTooManyRequests, introspectFrom("10.0.0.3", clientID, secret))
+
+	//* and one ip cycling through clients hits the ip limit
+	require.Equal(t, http.StatusOK, introspectFrom("10.0.0.1", otherID, otherSecret))
+	require.Equal(t, http.StatusTooManyRequests, introspectFrom("10.0.0.1", otherID, otherSecret))
+}
+
+func TestDeleteClientAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	fake := newOAuthTestStore(t, store)
+	store.EXPECT().
+		DeleteClient(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, id string) error {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			delete(fake.clients, id)
+			return nil
+		})
+	server := NewTestServer(t, store)
+
+	clientID, secret := registerTestClient(t, server, fake.user.Username, true, util.ScopeAccountsRead)
+
+	recorder := postOAuthForm(t, server, "/oauth/token", url.Values{"grant_type": {grantTypeClientCredentials}}, clientID, secret)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var tokenRsp oauthTokenResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &tokenRsp))
+
+	deleteClient := func(username string) *httptest.ResponseRecorder {
+		request, err := http.NewRequest(http.MethodDelete, "/oauth/clients/"+clientID, nil)
+		require.NoError(t, err)
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, username, util.DepositorRole, time.Minute)
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// only the owner may delete it
+	require.Equal(t, http.StatusNotFound, deleteClient(util.RandomOwner()).Code)
+	require.Equal(t, http.StatusOK, getWithBearer(t, server, "/accounts?page_id=1&page_size=5", tokenRsp.AccessToken).Code)
+
+	require.Equal(t, http.StatusOK, deleteClient(fake.user.Username).Code)
+
+	// its tokens stop working with it
+	recorder = getWithBearer(t, server, "/accounts?page_id=1&page_size=5", tokenRsp.AccessToken)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.Equal(t, http.StatusNotFound, deleteClient(fake.user.Username).Code)
+}
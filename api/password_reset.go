@@ -0,0 +1,125 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+)
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ! mails a one-time reset link. the answer is the same whether the address
+// ! is known or not, so the endpoint cannot be used to find accounts
+func (server *Server) requestPasswordReset(ctx *gin.Context) {
+
+	var req requestPasswordResetRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusOK, gin.H{})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	//* a deactivated user could not log in with the new password anyway
+	if user.DeactivatedAt.Valid {
+		ctx.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	resetToken, err := util.NewSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	//* only the hash is stored, a leaked table cannot be used to reset anyone
+	reset, err := server.store.CreatePasswordReset(ctx, db.CreatePasswordResetParams{
+		Username:  user.Username,
+		TokenHash: util.HashSecretCode(resetToken),
+		ExpiredAt: time.Now().Add(server.config.PasswordResetDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.sendPasswordResetEmail(user, resetToken, reset.ExpiredAt); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+func (server *Server) sendPasswordResetEmail(user db.User, resetToken string, expiredAt time.Time) error {
+	resetURL := server.config.AppBaseURL + "/reset_password?token=" + url.QueryEscape(resetToken)
+
+	subject := "Reset your Simple Bank password"
+	content := fmt.Sprintf(`Hello %s,<br/>
+	Someone asked to reset the password of your account.<br/>
+	Please <a href="%s">click here</a> to choose a new one, the link works once and expires at %s.<br/>
+	If this was not you, you can ignore this email.<br/>
+	`, html.EscapeString(user.FullName), html.EscapeString(resetURL), expiredAt.UTC().Format(time.RFC1123))
+
+	return server.mailer.SendEmail(subject, content, []string{user.Email})
+}
+
+type confirmPasswordResetRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=10"`
+}
+
+// ! sets the new password with the token from the mail. like a password change
+// ! it bumps password_changed_at and blocks every session
+func (server *Server) confirmPasswordReset(ctx *gin.Context) {
+
+	var req confirmPasswordResetRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hashedPassword, err := util.HashedPassword(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user, err := server.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		TokenHash:         util.HashSecretCode(req.Token),
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	})
+	if err != nil {
+		//* unknown, already used and expired tokens all look the same
+		if err == sql.ErrNoRows {
+			err := errors.New("invalid or expired reset token")
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
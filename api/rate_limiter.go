@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errRateLimited = errors.New("too many requests, try again later")
+
+// ^ fixed window counter kept in memory, good enough for a single instance.
+// ^ a limit of 0 lets everything through
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	windows   map[string]*rateWindow
+	lastPrune time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// allow counts one request for key and reports how long to wait when it is over the limit
+func (limiter *rateLimiter) allow(key string) (bool, time.Duration) {
+	if limiter.limit <= 0 {
+		return true, 0
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := time.Now()
+	limiter.prune(now)
+
+	w, ok := limiter.windows[key]
+	if !ok || now.Sub(w.start) >= limiter.window {
+		w = &rateWindow{start: now}
+		limiter.windows[key] = w
+	}
+
+	if w.count >= limiter.limit {
+		return false, w.start.Add(limiter.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// * drops finished windows at most once per window so the map does not grow forever
+func (limiter *rateLimiter) prune(now time.Time) {
+	if now.Sub(limiter.lastPrune) < limiter.window {
+		return
+	}
+	for key, w := range limiter.windows {
+		if now.Sub(w.start) >= limiter.window {
+			delete(limiter.windows, key)
+		}
+	}
+	limiter.lastPrune = now
+}
+
+// rateLimitByIP limits a route per client ip, used in front of the login endpoints
+func rateLimitByIP(limiter *rateLimiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ok, retryAfter := limiter.allow("ip:" + ctx.ClientIP()); !ok {
+			abortTooManyRequests(ctx, retryAfter, errRateLimited)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func abortTooManyRequests(ctx *gin.Context, retryAfter time.Duration, err error) {
+	setRetryAfter(ctx, retryAfter)
+	ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(err))
+}
+
+// * Retry-After is in whole seconds, rounded up so clients never retry too early
+func setRetryAfter(ctx *gin.Context, retryAfter time.Duration) {
+	ctx.Header("Retry-After", fmt.Sprint(int64(math.Ceil(retryAfter.Seconds()))))
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/scheduler"
+	"github.com/itsadijmbt/simple_bank/token"
+)
+
+type createScheduledTransferRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        int64  `json:"amount" binding:"required,gt=0"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	//* "@every 720h", "@monthly" or a cron expression like "0 9 1 * *", all in UTC
+	Schedule string `json:"schedule" binding:"required"`
+	//* defaults to now
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+type scheduledTransferResponse struct {
+	ID            int64     `json:"id"`
+	Owner         string    `json:"owner"`
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	Amount        int64     `json:"amount"`
+	Schedule      string    `json:"schedule"`
+	StartsAt      time.Time `json:"starts_at"`
+	//* left out when the order has no end date, hasn't run yet or won't run again
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+	LastError string     `json:"last_error"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// * the scheduler's lease stays internal
+func newScheduledTransferResponse(scheduled db.ScheduledTransfer) scheduledTransferResponse {
+
+	rsp := scheduledTransferResponse{
+		ID:            scheduled.ID,
+		Owner:         scheduled.Owner,
+		FromAccountID: scheduled.FromAccountID,
+		ToAccountID:   scheduled.ToAccountID,
+		Amount:        scheduled.Amount,
+		Schedule:      scheduled.Schedule,
+		StartsAt:      scheduled.StartsAt,
+		LastError:     scheduled.LastError,
+		CreatedAt:     scheduled.CreatedAt,
+	}
+
+	if scheduled.EndsAt.Valid {
+		rsp.EndsAt = &scheduled.EndsAt.Time
+	}
+	if scheduled.NextRunAt.Valid {
+		rsp.NextRunAt = &scheduled.NextRunAt.Time
+	}
+	if scheduled.LastRunAt.Valid {
+		rsp.LastRunAt = &scheduled.LastRunAt.Time
+	}
+	return rsp
+}
+
+// ! standing orders: the same transfer on a schedule, run by the scheduler in the background
+func (server *Server) createScheduledTransfer(ctx *gin.Context) {
+
+	var req createScheduledTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if fromAccount.Owner != authPayload.Username {
+		err := errors.New("account does not belong to the authed user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if _, valid = server.validAccount(ctx, req.ToAccountID, ""); !valid {
+		return
+	}
+
+	arg := db.CreateScheduledTransferParams{
+		Owner:         authPayload.Username,
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Amount:        req.Amount,
+		Schedule:      req.Schedule,
+		StartsAt:      time.Now().UTC(),
+	}
+	if req.StartsAt != nil {
+		arg.StartsAt = req.StartsAt.UTC()
+	}
+	if req.EndsAt != nil {
+		arg.EndsAt = sql.NullTime{Time: req.EndsAt.UTC(), Valid: true}
+	}
+
+	var err error
+	arg.NextRunAt, err = scheduler.NextRun(db.ScheduledTransfer{
+		Schedule: arg.Schedule,
+		StartsAt: arg.StartsAt,
+		EndsAt:   arg.EndsAt,
+	}, time.Now())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !arg.NextRunAt.Valid {
+		err := errors.New("schedule has no run before it ends")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	scheduled, err := server.store.CreateScheduledTransfer(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newScheduledTransferResponse(scheduled))
+}
+
+type listScheduledTransfersRequest struct {
+	PageID   int32 `form:"page_id" binding:"required,min=1"`
+	PageSize int32 `form:"page_size" binding:"required,min=1,max=10"`
+}
+
+func (server *Server) listScheduledTransfers(ctx *gin.Context) {
+
+	var req listScheduledTransfersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	scheduled, err := server.store.ListScheduledTransfers(ctx, db.ListScheduledTransfersParams{
+		Owner:  authPayload.Username,
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]scheduledTransferResponse, 0, len(scheduled))
+	for _, order := range scheduled {
+		rsp = append(rsp, newScheduledTransferResponse(order))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+func (server *Server) getScheduledTransfer(ctx *gin.Context) {
+
+	scheduled, ok := server.ownedScheduledTransfer(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newScheduledTransferResponse(scheduled))
+}
+
+// ^ cancels the standing order, transfers it already made stay
+func (server *Server) deleteScheduledTransfer(ctx *gin.Context) {
+
+	scheduled, ok := server.ownedScheduledTransfer(ctx)
+	if !ok {
+		return
+	}
+
+	if err := server.store.DeleteScheduledTransfer(ctx, scheduled.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+type scheduledTransferUriRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// * someone else's standing order is reported as not found
+func (server *Server) ownedScheduledTransfer(ctx *gin.Context) (db.ScheduledTransfer, bool) {
+
+	var uri scheduledTransferUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.ScheduledTransfer{}, false
+	}
+
+	scheduled, err := server.store.GetScheduledTransfer(ctx, uri.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return db.ScheduledTransfer{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return db.ScheduledTransfer{}, false
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if scheduled.Owner != authPayload.Username {
+		ctx.JSON(http.StatusNotFound, errorResponse(sql.ErrNoRows))
+		return db.ScheduledTransfer{}, false
+	}
+
+	return scheduled, true
+}
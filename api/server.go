@@ -8,6 +8,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	db "github.com/itsadijmbt/simple_bank/db/sqlc"
 	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/mail"
 	"github.com/itsadijmbt/simple_bank/token"
 )
 
@@ -19,6 +20,15 @@ type Server struct {
 	router     *gin.Engine
 	config     util.Config
 	tokenMaker token.Maker
+	//! checked by the auth middleware so tokens can be cut off before expiry
+	revocations token.RevocationStore
+	//! counts login attempts per ip and per username
+	loginLimiter *rateLimiter
+	//! counts oauth client calls per ip and per client, apart from the logins
+	oauthLimiter *rateLimiter
+	mailer       mail.EmailSender
+	//! applies to every user and currency a banker hasn't set limits for
+	transferLimits db.TransferLimitsByCurrency
 }
 
 // ! NewServer wires together storage, routes, and middleware.
@@ -26,11 +36,28 @@ type Server struct {
 func NewServer(config util.Config, store db.Store) (*Server, error) {
 
 	//* 0 token maker
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := token.NewMakerFromConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("invalid token %w", err)
 	}
 
+	mailer, err := mail.NewSenderFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mailer %w", err)
+	}
+
+	//* totp secrets are sealed with AES, which only takes 16, 24 or 32 byte keys
+	switch len(config.TOTPEncryptionKey) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("invalid totp encryption key: must be 16, 24 or 32 bytes")
+	}
+
+	transferLimits, err := db.TransferLimitsFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transfer limits %w", err)
+	}
+
 	//* 1. Allocate the application struct.
 	//*    The struct keeps shared dependencies (DB, config, logger, …)
 	//*    so handlers can access them through `server.<field>`.
@@ -41,6 +68,20 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 
 		// adding a token maker in this
 		tokenMaker: tokenMaker,
+
+		//* postgres is the source of truth, the cache keeps it off the hot path
+		revocations: token.NewCachedRevocationStore(
+			token.NewPostgresRevocationStore(store),
+			config.RevocationCacheTTL,
+		),
+
+		loginLimiter: newRateLimiter(config.LoginRateLimit, config.LoginRateWindow),
+
+		oauthLimiter: newRateLimiter(config.OAuthRateLimit, config.OAuthRateWindow),
+
+		mailer: mailer,
+
+		transferLimits: transferLimits,
 	}
 
 	//^calling server setup
@@ -60,11 +101,18 @@ func (server *Server) setupRouter() {
 	//^  (i.e., a pointer to validator.Validate struct), so please try to extract it as that."
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", validCurrency)
+		v.RegisterValidation("scope", validScope)
 	}
 
 	//we create an auth route to protect the routes via the middleware
 
-	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
+	authRoutes := router.Group("/").Use(
+		authMiddleware(server.tokenMaker, server.revocations, server.store),
+		rejectScopedCredentials(),
+	)
+
+	//* api keys and oauth tokens are only let in on routes that name the scope they need
+	scopedRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker, server.revocations, server.store))
 
 	//!now we use auth router for desired routes
 
@@ -74,19 +122,118 @@ func (server *Server) setupRouter() {
 	//*    Path segments that start with `:` become URI parameters.
 	//*-------------------------------------------------------------
 	//* POST /accounts        → createAccount(ctx *gin.Context)
-	authRoutes.POST("/accounts", server.createAccount)
+	scopedRoutes.POST("/accounts", requireScope(util.ScopeAccountsWrite), requireVerifiedEmail(), server.createAccount)
 
 	//* GET  /accounts/:id    → getAccount(ctx *gin.Context)
 	//*      The `:id` token is read with ctx.Param("id") or via ShouldBindUri.
-	authRoutes.GET("/accounts/:id", server.getAccount)
+	scopedRoutes.GET("/accounts/:id", requireScope(util.ScopeAccountsRead), server.getAccount)
+
+	scopedRoutes.GET("/accounts", requireScope(util.ScopeAccountsRead), server.listAccount)
+
+	scopedRoutes.GET("/accounts/:id/entries", requireScope(util.ScopeAccountsRead), server.listAccountEntries)
+
+	scopedRoutes.GET("/accounts/:id/statement", requireScope(util.ScopeAccountsRead), server.getStatement)
+
+	//* owners move their own money in and out, bankers can do it for them
+	scopedRoutes.POST("/accounts/:id/deposit", requireScope(util.ScopeTransfersWrite), requireVerifiedEmail(), server.deposit)
+
+	scopedRoutes.POST("/accounts/:id/withdraw", requireScope(util.ScopeTransfersWrite), requireVerifiedEmail(), server.withdraw)
+
+	//* banker only routes, authorizeRoles runs after the auth middleware
+	authRoutes.POST("/accounts/:id/freeze", authorizeRoles(util.BankerRole), server.freezeAccount)
+
+	authRoutes.POST("/accounts/:id/unfreeze", authorizeRoles(util.BankerRole), server.unfreezeAccount)
+
+	authRoutes.PUT("/accounts/:id/overdraft_limit", authorizeRoles(util.BankerRole), server.setOverdraftLimit)
+
+	authRoutes.PUT("/accounts/:id/transfer_limits", authorizeRoles(util.BankerRole), server.setAccountTransferLimits)
+
+	authRoutes.GET("/accounts/:id/entries/verify", authorizeRoles(util.BankerRole), server.verifyEntryChain)
+
+	authRoutes.POST("/users/:username/unlock", authorizeRoles(util.BankerRole), server.unlockUser)
+
+	authRoutes.PUT("/users/:username/transfer_limits", authorizeRoles(util.BankerRole), server.setUserTransferLimits)
+
+	authRoutes.PATCH("/users/:username", server.updateUser)
+
+	authRoutes.POST("/users/:username/deactivate", server.deactivateUser)
+
+	scopedRoutes.POST("/transfers", requireScope(util.ScopeTransfersWrite), requireVerifiedEmail(), server.createTransfer)
+
+	authRoutes.POST("/transfers/:id/reverse", authorizeRoles(util.BankerRole), server.reverseTransfer)
 
-	authRoutes.GET("/accounts", server.listAccount)
+	authRoutes.GET("/exchange_rates", server.listExchangeRates)
 
-	authRoutes.POST("/transfers", server.createTransfer)
+	authRoutes.GET("/reconciliation_runs", authorizeRoles(util.BankerRole), server.listReconciliationRuns)
+
+	authRoutes.GET("/reconciliation_runs/:id", authorizeRoles(util.BankerRole), server.getReconciliationRun)
+
+	authRoutes.POST("/scheduled_transfers", requireVerifiedEmail(), server.createScheduledTransfer)
+
+	authRoutes.GET("/scheduled_transfers", server.listScheduledTransfers)
+
+	authRoutes.GET("/scheduled_transfers/:id", server.getScheduledTransfer)
+
+	authRoutes.DELETE("/scheduled_transfers/:id", server.deleteScheduledTransfer)
+
+	authRoutes.POST("/api_keys", server.createApiKey)
+
+	authRoutes.GET("/api_keys", server.listApiKeys)
+
+	authRoutes.GET("/api_keys/:id", server.getApiKey)
+
+	authRoutes.PATCH("/api_keys/:id", server.updateApiKey)
+
+	authRoutes.DELETE("/api_keys/:id", server.deleteApiKey)
+
+	authRoutes.POST("/oauth/clients", server.createClient)
+
+	authRoutes.DELETE("/oauth/clients/:id", server.deleteClient)
+
+	//* the consent step of the authorization code flow needs the user logged in
+	authRoutes.GET("/oauth/authorize", server.getAuthorize)
+
+	authRoutes.POST("/oauth/authorize", server.postAuthorize)
+
+	authRoutes.POST("/users/change_password", server.changePassword)
+
+	authRoutes.POST("/users/logout", server.logoutUser)
+
+	authRoutes.POST("/users/logout_all", server.logoutAllSessions)
+
+	authRoutes.POST("/users/totp/setup", server.setupTOTP)
+
+	authRoutes.POST("/users/totp/enable", server.enableTOTP)
+
+	authRoutes.POST("/users/totp/disable", server.disableTOTP)
 
 	router.POST("/users", server.createUser)
 
-	router.POST("/users/login", server.loginUser)
+	//* the link in the verification mail points here
+	router.GET("/verify_email", server.verifyEmail)
+
+	//* limited like the login, each request sends a mail
+	router.POST("/users/password_reset", rateLimitByIP(server.loginLimiter), server.requestPasswordReset)
+
+	router.POST("/users/password_reset/confirm", rateLimitByIP(server.loginLimiter), server.confirmPasswordReset)
+
+	router.POST("/users/login", rateLimitByIP(server.loginLimiter), server.loginUser)
+
+	//* second step of the login for users with totp enabled
+	router.POST("/users/login/mfa", rateLimitByIP(server.loginLimiter), server.loginMFA)
+
+	//* refresh tokens are checked against the sessions table, not the auth middleware
+	router.POST("/tokens/renew_access", server.renewAccessToken)
+
+	//* clients authenticate themselves on these, limited per ip against guessing secrets
+	//* and per client once authenticated (see authenticateClient), apart from the logins
+	router.POST("/oauth/token", rateLimitByIP(server.oauthLimiter), server.oauthToken)
+
+	router.POST("/oauth/introspect", rateLimitByIP(server.oauthLimiter), server.introspectToken)
+
+	router.POST("/oauth/revoke", rateLimitByIP(server.oauthLimiter), server.revokeToken)
+
+	router.GET("/.well-known/jwks.json", server.getJWKS)
 	//* 4. Attach the configured router back to the server struct
 	//*    so `main.go` can call `server.router.Run(addr)`.
 	server.router = router
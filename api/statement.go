@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+)
+
+// ^ a statement lists every entry of its period, so the period is capped instead of paged
+const maxStatementPeriod = 366 * 24 * time.Hour
+
+type statementRequest struct {
+	//* RFC 3339, from is inclusive and to exclusive
+	From time.Time `form:"from" binding:"required"`
+	To   time.Time `form:"to" binding:"required,gtfield=From"`
+}
+
+type statementResponse struct {
+	AccountID      int64                   `json:"account_id"`
+	Currency       string                  `json:"currency"`
+	From           time.Time               `json:"from"`
+	To             time.Time               `json:"to"`
+	OpeningBalance int64                   `json:"opening_balance"`
+	TotalCredits   int64                   `json:"total_credits"`
+	TotalDebits    int64                   `json:"total_debits"`
+	ClosingBalance int64                   `json:"closing_balance"`
+	Entries        []statementLineResponse `json:"entries"`
+}
+
+// ^ one entry of the statement, deposits and withdrawals have no transfer and no counterparty
+type statementLineResponse struct {
+	ID                    int64     `json:"id"`
+	Amount                int64     `json:"amount"`
+	BalanceAfter          int64     `json:"balance_after"`
+	CreatedAt             time.Time `json:"created_at"`
+	TransferID            *int64    `json:"transfer_id,omitempty"`
+	CounterpartyAccountID *int64    `json:"counterparty_account_id,omitempty"`
+	CounterpartyOwner     *string   `json:"counterparty_owner,omitempty"`
+}
+
+func newStatementLineResponse(row db.ListStatementEntriesRow) statementLineResponse {
+
+	rsp := statementLineResponse{
+		ID:           row.ID,
+		Amount:       row.Amount,
+		BalanceAfter: row.BalanceAfter,
+		CreatedAt:    row.CreatedAt,
+	}
+
+	if row.TransferID.Valid {
+		rsp.TransferID = &row.TransferID.Int64
+	}
+	if row.CounterpartyAccountID.Valid {
+		rsp.CounterpartyAccountID = &row.CounterpartyAccountID.Int64
+	}
+	if row.CounterpartyOwner.Valid {
+		rsp.CounterpartyOwner = &row.CounterpartyOwner.String
+	}
+	return rsp
+}
+
+func (server *Server) getStatement(ctx *gin.Context) {
+
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req statementRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.To.Sub(req.From) > maxStatementPeriod {
+		err := errors.New("statement period is longer than a year")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	//* bankers may look at any account, depositors only at their own
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Role != util.BankerRole && authPayload.Username != account.Owner {
+		err := errors.New("account does not belong to authenticated user")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	statement, err := server.store.StatementTx(ctx, db.StatementTxParams{
+		AccountID: account.ID,
+		From:      req.From,
+		To:        req.To,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := statementResponse{
+		AccountID:      account.ID,
+		Currency:       account.Currency,
+		From:           req.From,
+		To:             req.To,
+		OpeningBalance: statement.OpeningBalance,
+		TotalCredits:   statement.TotalCredits,
+		TotalDebits:    statement.TotalDebits,
+		ClosingBalance: statement.ClosingBalance,
+		Entries:        make([]statementLineResponse, 0, len(statement.Entries)),
+	}
+	for _, row := range statement.Entries {
+		rsp.Entries = append(rsp.Entries, newStatementLineResponse(row))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/itsadijmbt/simple_bank/db/mock"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGetStatementAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	statement := db.StatementTxResult{
+		OpeningBalance: 100,
+		TotalCredits:   50,
+		TotalDebits:    30,
+		ClosingBalance: 120,
+		Entries: []db.ListStatementEntriesRow{
+			{ID: 1, Amount: 50, BalanceAfter: 150},
+			{
+				ID:                    2,
+				Amount:                -30,
+				BalanceAfter:          120,
+				TransferID:            sql.NullInt64{Int64: 7, Valid: true},
+				CounterpartyAccountID: sql.NullInt64{Int64: 9, Valid: true},
+				CounterpartyOwner:     sql.NullString{String: "someone_else", Valid: true},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		role          string
+		from          time.Time
+		to            time.Time
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			role:     util.DepositorRole,
+			from:     from,
+			to:       to,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					StatementTx(gomock.Any(), gomock.Eq(db.StatementTxParams{AccountID: account.ID, From: from, To: to})).
+					Times(1).
+					Return(statement, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var got statementResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &got))
+				require.Equal(t, account.ID, got.AccountID)
+				require.Equal(t, int64(100), got.OpeningBalance)
+				require.Equal(t, int64(120), got.ClosingBalance)
+				require.Len(t, got.Entries, 2)
+				require.Nil(t, got.Entries[0].TransferID)
+				require.Nil(t, got.Entries[0].CounterpartyOwner)
+				require.Equal(t, int64(7), *got.Entries[1].TransferID)
+				require.Equal(t, int64(9), *got.Entries[1].CounterpartyAccountID)
+				require.Equal(t, "someone_else", *got.Entries[1].CounterpartyOwner)
+
+				//* plain values, not sql.Null* objects
+				var raw struct {
+					Entries []map[string]any `json:"entries"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &raw))
+				require.NotContains(t, raw.Entries[0], "transfer_id")
+				require.Equal(t, float64(7), raw.Entries[1]["transfer_id"])
+			},
+		},
+		{
+			name:     "BankerOtherAccount",
+			username: "some_banker",
+			role:     util.BankerRole,
+			from:     from,
+			to:       to,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					StatementTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(statement, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:     "OtherDepositor",
+			username: "someone_else",
+			role:     util.DepositorRole,
+			from:     from,
+			to:       to,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					StatementTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:     "NotFound",
+			username: user.Username,
+			role:     util.DepositorRole,
+			from:     from,
+			to:       to,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.Account{}, sql.ErrNoRows)
+				store.EXPECT().
+					StatementTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "ToBeforeFrom",
+			username: user.Username,
+			role:     util.DepositorRole,
+			from:     to,
+			to:       from,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					StatementTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:     "PeriodTooLong",
+			username: user.Username,
+			role:     util.DepositorRole,
+			from:     from,
+			to:       from.AddDate(2, 0, 0),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					StatementTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthUserWithRole(store, tc.role)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			query := url.Values{}
+			query.Set("from", tc.from.Format(time.RFC3339))
+			query.Set("to", tc.to.Format(time.RFC3339))
+			path := fmt.Sprintf("/accounts/%d/statement?%s", account.ID, query.Encode())
+			request, err := http.NewRequest(http.MethodGet, path, nil)
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.username, tc.role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
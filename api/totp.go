@@ -0,0 +1,321 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+)
+
+const (
+	//* shown as the service name in authenticator apps
+	totpIssuer        = "SimpleBank"
+	recoveryCodeCount = 10
+)
+
+var errTOTPNotEnabled = errors.New("two-factor authentication is not enabled")
+
+type setupTOTPResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// ! starts enrolment: stores a new secret (encrypted, not yet enabled) and hands
+// ! back the otpauth uri for the QR code, nothing changes for login until enable
+func (server *Server) setupTOTP(ctx *gin.Context) {
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if user.TotpEnabled {
+		err := errors.New("two-factor authentication is already enabled")
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+		return
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	encrypted, err := util.EncryptSecret(server.config.TOTPEncryptionKey, []byte(secret))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = server.store.UpdateUserTOTP(ctx, db.UpdateUserTOTPParams{
+		Username:    user.Username,
+		TotpSecret:  encrypted,
+		TotpEnabled: false,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, setupTOTPResponse{
+		Secret:          secret,
+		ProvisioningURI: util.TOTPProvisioningURI(totpIssuer, user.Username, secret),
+	})
+}
+
+type enableTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type enableTOTPResponse struct {
+	//* only ever shown here, the database keeps hashes
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ! finishes enrolment once the user proves their app produces the right codes
+func (server *Server) enableTOTP(ctx *gin.Context) {
+
+	var req enableTOTPRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if user.TotpEnabled {
+		err := errors.New("two-factor authentication is already enabled")
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+		return
+	}
+
+	if len(user.TotpSecret) == 0 {
+		err := errors.New("two-factor setup has not been started")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err := server.checkTOTPCode(user, req.Code); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	codes, err := util.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = util.HashRecoveryCode(code)
+	}
+
+	_, err = server.store.SetTOTPTx(ctx, db.SetTOTPTxParams{
+		Username:           user.Username,
+		TotpSecret:         user.TotpSecret,
+		TotpEnabled:        true,
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enableTOTPResponse{RecoveryCodes: codes})
+}
+
+type disableTOTPRequest struct {
+	Password string `json:"password" binding:"required,min=10"`
+}
+
+// ! turns two-factor off again, the password is asked for so a stolen
+// ! access token alone cannot weaken the account
+func (server *Server) disableTOTP(ctx *gin.Context) {
+
+	var req disableTOTPRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := util.CheckPassword(req.Password, user.HashedPassword); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	user, err = server.store.SetTOTPTx(ctx, db.SetTOTPTxParams{
+		Username:    user.Username,
+		TotpSecret:  nil,
+		TotpEnabled: false,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type loginMFARequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" binding:"required_without=Code"`
+}
+
+// ! second step of a two-factor login: the challenge token from /users/login
+// ! plus a totp code (or an unused recovery code) is exchanged for the real tokens
+func (server *Server) loginMFA(ctx *gin.Context) {
+
+	var req loginMFARequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload, err := server.tokenMaker.VerifyToken(req.MFAToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if ok, retryAfter := server.loginLimiter.allow("user:" + payload.Username); !ok {
+		abortTooManyRequests(ctx, retryAfter, errRateLimited)
+		return
+	}
+
+	if payload.Role != util.MFAChallengeRole {
+		err := errors.New("not an mfa challenge token")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	revoked, err := server.revocations.IsRevoked(ctx, payload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if revoked {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(token.ErrRevokedToken))
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !user.TotpEnabled {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errTOTPNotEnabled))
+		return
+	}
+
+	if user.DeactivatedAt.Valid {
+		ctx.JSON(http.StatusForbidden, errorResponse(errUserDeactivated))
+		return
+	}
+
+	if err := payload.ValidSince(user.PasswordChangedAt); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	//* wrong codes count as failed logins, otherwise 6 digits are easy to guess
+	if !server.checkLoginAllowed(ctx, user) {
+		return
+	}
+
+	if req.Code != "" {
+		if err := server.checkTOTPCode(user, req.Code); err != nil {
+			server.recordFailedLogin(ctx, user.Username, err)
+			return
+		}
+	} else {
+		//* marks the code used in the same statement, so it works only once
+		_, err := server.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+			Username: user.Username,
+			CodeHash: util.HashRecoveryCode(req.RecoveryCode),
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				server.recordFailedLogin(ctx, user.Username, errors.New("invalid recovery code"))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	//* a challenge is good for one login only
+	if err := server.revocations.Revoke(ctx, payload); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.resetFailedLogins(ctx, user); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp, err := server.createLoginSession(ctx, user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+func (server *Server) checkTOTPCode(user db.User, code string) error {
+	secret, err := util.DecryptSecret(server.config.TOTPEncryptionKey, user.TotpSecret)
+	if err != nil {
+		return err
+	}
+
+	if !util.ValidateTOTP(code, string(secret), time.Now()) {
+		return errors.New("invalid two-factor code")
+	}
+	return nil
+}
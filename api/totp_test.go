@@ -0,0 +1,431 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mockdb "github.com/itsadijmbt/simple_bank/db/mock"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// * a user with a totp secret sealed under key, enabled or still pending
+func randomTOTPUser(t *testing.T, key string, enabled bool) (user db.User, password string, secret string) {
+	user, password = randomUser(t)
+
+	secret, err := util.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	user.TotpSecret, err = util.EncryptSecret(key, []byte(secret))
+	require.NoError(t, err)
+	user.TotpEnabled = enabled
+	return
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	code, err := util.TOTPCode(secret, at)
+	require.NoError(t, err)
+	return code
+}
+
+func TestEnableTOTPAPI(t *testing.T) {
+	key := util.RandomString(32)
+	pending, _, secret := randomTOTPUser(t, key, false)
+	enabled, _, _ := randomTOTPUser(t, key, true)
+	fresh, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		user          db.User
+		code          string
+		buildStubs    func(store *mockdb.MockStore, user db.User)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			user: pending,
+			code: totpCode(t, secret, time.Now()),
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(2).
+					Return(user, nil)
+				store.EXPECT().
+					SetTOTPTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.SetTOTPTxParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, user.TotpSecret, arg.TotpSecret)
+						require.True(t, arg.TotpEnabled)
+						require.Len(t, arg.RecoveryCodeHashes, recoveryCodeCount)
+						return user, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp enableTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp.RecoveryCodes, recoveryCodeCount)
+			},
+		},
+		{
+			name: "InvalidCode",
+			user: pending,
+			code: totpCode(t, secret, time.Now().Add(time.Hour)),
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(2).
+					Return(user, nil)
+				store.EXPECT().
+					SetTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "SetupNotStarted",
+			user: fresh,
+			code: "123456",
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(2).
+					Return(user, nil)
+				store.EXPECT().
+					SetTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "AlreadyEnabled",
+			user: enabled,
+			code: "123456",
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(2).
+					Return(user, nil)
+				store.EXPECT().
+					SetTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidCodeFormat",
+			user: pending,
+			code: "12ab",
+			buildStubs: func(store *mockdb.MockStore, user db.User) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store, tc.user)
+
+			server := NewTestServer(t, store)
+			server.config.TOTPEncryptionKey = key
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"code": tc.code})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/totp/enable", bytes.NewReader(data))
+			require.NoError(t, err)
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.user.Username, tc.user.Role, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestSetupTOTPAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(2).
+		Return(user, nil)
+
+	server := NewTestServer(t, store)
+
+	var sealed []byte
+	store.EXPECT().
+		UpdateUserTOTP(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.UpdateUserTOTPParams) (db.User, error) {
+			require.Equal(t, user.Username, arg.Username)
+			require.False(t, arg.TotpEnabled)
+			sealed = arg.TotpSecret
+			return user, nil
+		})
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/users/totp/setup", nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp setupTOTPResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Contains(t, rsp.ProvisioningURI, "secret="+rsp.Secret)
+
+	//* only the encrypted secret reaches the database
+	secret, err := util.DecryptSecret(server.config.TOTPEncryptionKey, sealed)
+	require.NoError(t, err)
+	require.Equal(t, rsp.Secret, string(secret))
+}
+
+func TestLoginMFAAPI(t *testing.T) {
+	key := util.RandomString(32)
+	user, _, secret := randomTOTPUser(t, key, true)
+	recoveryCode := "abcde-fghjk"
+
+	testCases := []struct {
+		name          string
+		body          func(mfaToken string) gin.H
+		role          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: func(mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": totpCode(t, secret, time.Now())}
+			},
+			role: util.MFAChallengeRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp.AccessToken)
+				require.Equal(t, user.Username, rsp.User.Username)
+			},
+		},
+		{
+			name: "RecoveryCode",
+			body: func(mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken, "recovery_code": recoveryCode}
+			},
+			role: util.MFAChallengeRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Eq(db.UseRecoveryCodeParams{
+						Username: user.Username,
+						CodeHash: util.HashRecoveryCode(recoveryCode),
+					})).
+					Times(1).
+					Return(db.RecoveryCode{Username: user.Username}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "UsedRecoveryCode",
+			body: func(mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken, "recovery_code": recoveryCode}
+			},
+			role: util.MFAChallengeRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RecoveryCode{}, sql.ErrNoRows)
+				store.EXPECT().
+					RecordFailedLogin(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidCode",
+			body: func(mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": totpCode(t, secret, time.Now().Add(time.Hour))}
+			},
+			role: util.MFAChallengeRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RecordFailedLogin(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "AccessTokenInsteadOfChallenge",
+			body: func(mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken, "code": totpCode(t, secret, time.Now())}
+			},
+			role: util.DepositorRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NoSecondFactor",
+			body: func(mfaToken string) gin.H {
+				return gin.H{"mfa_token": mfaToken}
+			},
+			role: util.MFAChallengeRole,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			server.config.TOTPEncryptionKey = key
+			recorder := httptest.NewRecorder()
+
+			mfaToken, _, err := server.tokenMaker.CreateToken(user.Username, tc.role, token.TokenTypeAccess, time.Minute)
+			require.NoError(t, err)
+
+			data, err := json.Marshal(tc.body(mfaToken))
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+// ^ a challenge token is spent by a successful login and cannot open the api
+func TestMFAChallengeToken(t *testing.T) {
+	key := util.RandomString(32)
+	user, _, secret := randomTOTPUser(t, key, true)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(1)
+
+	server := NewTestServer(t, store)
+	server.config.TOTPEncryptionKey = key
+
+	mfaToken, _, err := server.tokenMaker.CreateToken(user.Username, util.MFAChallengeRole, token.TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	//* the auth middleware refuses it outright
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/accounts", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" "+mfaToken)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	data, err := json.Marshal(gin.H{"mfa_token": mfaToken, "code": totpCode(t, secret, time.Now())})
+	require.NoError(t, err)
+
+	for _, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(data))
+		require.NoError(t, err)
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, want, recorder.Code)
+	}
+}
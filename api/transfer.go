@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -12,10 +13,11 @@ import (
 )
 
 type transferRequest struct {
-	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
-	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1"`
-	Amount        int64  `json:"amount"  binding:"required,gt=0"`
-	Currency      string `json:"currency" binding:"required,currency"`
+	FromAccountID int64 `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64 `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        int64 `json:"amount"  binding:"required,gt=0"`
+	//* currency of the source account, the amount is debited in it
+	Currency string `json:"currency" binding:"required,currency"`
 }
 
 // ^ Writting a custom validator
@@ -30,19 +32,30 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 
 	}
 
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	//* a retry is answered before the accounts are checked again, it gets what the first request got
+	idempotency, valid := newTransferIdempotency(ctx, authPayload.Username, req)
+	if !valid {
+		return
+	}
+	if idempotency != nil && server.replayIdempotent(ctx, idempotency) {
+		return
+	}
+
 	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
 	if !valid {
 		return
 	}
 
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 	if fromAccount.Owner != authPayload.Username {
 		err := errors.New("account does not belong to the authed user")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
 
-	_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
+	//* the destination may hold another currency, TransferTx converts at the stored rate
+	_, valid = server.validAccount(ctx, req.ToAccountID, "")
 
 	if !valid {
 		return
@@ -52,11 +65,34 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		FromAccountID: req.FromAccountID,
 		ToAccountID:   req.ToAccountID,
 		Amount:        req.Amount,
+		Idempotency:   idempotency,
+		DefaultLimits: server.transferLimits,
 	}
 
 	result, err := server.store.TransferTx(ctx, arg)
 
 	if err != nil {
+		//* a concurrent retry with the same key won, answer with its response
+		if errors.Is(err, db.ErrIdempotencyKeyExists) && server.replayIdempotent(ctx, idempotency) {
+			return
+		}
+		var limitErr *db.LimitExceededError
+		if errors.As(err, &limitErr) {
+			ctx.JSON(http.StatusUnprocessableEntity, limitExceededResponse(limitErr))
+			return
+		}
+		if errors.Is(err, db.ErrInsufficientFunds) || errors.Is(err, db.ErrNoExchangeRate) || errors.Is(err, db.ErrAmountTooSmall) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		if errors.Is(err, db.ErrClearingAccount) {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		if errors.Is(err, db.ErrSameAccount) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
@@ -78,7 +114,26 @@ func (server *Server) validAccount(ctx *gin.Context, accountId int64, currency s
 		return account, false
 	}
 
-	if account.Currency != currency {
+	if account.IsFrozen {
+		err := fmt.Errorf("account [%d] is frozen", account.ID)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return account, false
+	}
+
+	//* accounts of deactivated users are read-only, nothing goes in or out
+	owner, err := server.store.GetUser(ctx, account.Owner)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return account, false
+	}
+	if owner.DeactivatedAt.Valid {
+		err := fmt.Errorf("account [%d] belongs to a deactivated user", account.ID)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return account, false
+	}
+
+	//^ an empty currency accepts whatever the account holds
+	if currency != "" && account.Currency != currency {
 		err := fmt.Errorf("account [%d] currency mismatch: %s vs %s", account.ID, account.Currency, currency)
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return account, false
@@ -87,3 +142,50 @@ func (server *Server) validAccount(ctx *gin.Context, accountId int64, currency s
 	return account, true
 
 }
+
+type reverseTransferUriRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type reverseTransferRequest struct {
+	//* in the original's source currency, left out to reverse the whole remaining amount
+	Amount int64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+// ! banker only, sends a mistaken transfer back in full or in part
+func (server *Server) reverseTransfer(ctx *gin.Context) {
+
+	var uri reverseTransferUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	//* an empty body is a full reversal
+	var req reverseTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	result, err := server.store.ReverseTransferTx(ctx, db.ReverseTransferTxParams{
+		TransferID: uri.ID,
+		Amount:     req.Amount,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		case errors.Is(err, db.ErrTransferReversed):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		case errors.Is(err, db.ErrReversalTooLarge), errors.Is(err, db.ErrReverseReversal),
+			errors.Is(err, db.ErrInsufficientFunds), errors.Is(err, db.ErrAmountTooSmall):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/lib/pq"
+)
+
+// * the client gets which limit it ran into, not just a message
+func limitExceededResponse(err *db.LimitExceededError) gin.H {
+	return gin.H{"error": err.Error(), "limit": err}
+}
+
+// ^ a limit left out (or null) falls back to the configured default for users and to no limit for accounts
+type transferLimitRequest struct {
+	MaxAmount   *int64 `json:"max_amount" binding:"omitempty,gt=0"`
+	DailyAmount *int64 `json:"daily_amount" binding:"omitempty,gt=0"`
+	DailyCount  *int32 `json:"daily_count" binding:"omitempty,gt=0"`
+}
+
+// ^ user limits are kept per currency, amounts in different currencies can't be summed
+type userTransferLimitRequest struct {
+	Currency string `json:"currency" binding:"required,currency"`
+	transferLimitRequest
+}
+
+// ^ a user limit has owner and currency, an account limit account_id; a limit left out falls back like in the request
+type transferLimitResponse struct {
+	ID          int64     `json:"id"`
+	Owner       *string   `json:"owner,omitempty"`
+	Currency    *string   `json:"currency,omitempty"`
+	AccountID   *int64    `json:"account_id,omitempty"`
+	MaxAmount   *int64    `json:"max_amount,omitempty"`
+	DailyAmount *int64    `json:"daily_amount,omitempty"`
+	DailyCount  *int32    `json:"daily_count,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func newTransferLimitResponse(limit db.TransferLimit) transferLimitResponse {
+
+	rsp := transferLimitResponse{
+		ID:        limit.ID,
+		UpdatedAt: limit.UpdatedAt,
+	}
+
+	if limit.Owner.Valid {
+		rsp.Owner = &limit.Owner.String
+	}
+	if limit.Currency.Valid {
+		rsp.Currency = &limit.Currency.String
+	}
+	if limit.AccountID.Valid {
+		rsp.AccountID = &limit.AccountID.Int64
+	}
+	if limit.MaxAmount.Valid {
+		rsp.MaxAmount = &limit.MaxAmount.Int64
+	}
+	if limit.DailyAmount.Valid {
+		rsp.DailyAmount = &limit.DailyAmount.Int64
+	}
+	if limit.DailyCount.Valid {
+		rsp.DailyCount = &limit.DailyCount.Int32
+	}
+	return rsp
+}
+
+func (req transferLimitRequest) nullable() (sql.NullInt64, sql.NullInt64, sql.NullInt32) {
+	var maxAmount, dailyAmount sql.NullInt64
+	var dailyCount sql.NullInt32
+	if req.MaxAmount != nil {
+		maxAmount = sql.NullInt64{Int64: *req.MaxAmount, Valid: true}
+	}
+	if req.DailyAmount != nil {
+		dailyAmount = sql.NullInt64{Int64: *req.DailyAmount, Valid: true}
+	}
+	if req.DailyCount != nil {
+		dailyCount = sql.NullInt32{Int32: *req.DailyCount, Valid: true}
+	}
+	return maxAmount, dailyAmount, dailyCount
+}
+
+// ! banker only, replaces the limits of one user across all their accounts in one currency
+func (server *Server) setUserTransferLimits(ctx *gin.Context) {
+
+	var uri userUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req userTransferLimitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	arg := db.UpsertUserTransferLimitParams{
+		Owner:    sql.NullString{String: uri.Username, Valid: true},
+		Currency: sql.NullString{String: req.Currency, Valid: true},
+	}
+	arg.MaxAmount, arg.DailyAmount, arg.DailyCount = req.nullable()
+
+	limit, err := server.store.UpsertUserTransferLimit(ctx, arg)
+	if err != nil {
+		server.transferLimitError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newTransferLimitResponse(limit))
+}
+
+// ! banker only, replaces the limits of one account, on top of its owner's
+func (server *Server) setAccountTransferLimits(ctx *gin.Context) {
+
+	var uri getAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req transferLimitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	arg := db.UpsertAccountTransferLimitParams{
+		AccountID: sql.NullInt64{Int64: uri.ID, Valid: true},
+	}
+	arg.MaxAmount, arg.DailyAmount, arg.DailyCount = req.nullable()
+
+	limit, err := server.store.UpsertAccountTransferLimit(ctx, arg)
+	if err != nil {
+		server.transferLimitError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newTransferLimitResponse(limit))
+}
+
+func (server *Server) transferLimitError(ctx *gin.Context, err error) {
+	//* the user or account doesn't exist
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+}
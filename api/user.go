@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	db "github.com/itsadijmbt/simple_bank/db/sqlc"
 	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
 	"github.com/lib/pq"
 )
 
@@ -24,21 +26,32 @@ type userResponse struct {
 	//& HashedPassword    string    `json:"hashed_password"`
 	FullName          string    `json:"full_name"`
 	Email             string    `json:"email"`
+	Role              string    `json:"role"`
+	IsEmailVerified   bool      `json:"is_email_verified"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	//* left out for active users
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
 }
 
 // ! coverts user Obj to userRes as it contains sensitive data
 func newUserResponse(user db.User) userResponse {
 
-	return userResponse{
+	rsp := userResponse{
 		Username:          user.Username,
 		Email:             user.Email,
 		FullName:          user.FullName,
+		Role:              user.Role,
+		IsEmailVerified:   user.IsEmailVerified,
 		PasswordChangedAt: user.PasswordChangedAt,
 		CreatedAt:         user.CreatedAt,
 	}
 
+	if user.DeactivatedAt.Valid {
+		rsp.DeactivatedAt = &user.DeactivatedAt.Time
+	}
+	return rsp
+
 }
 
 func (server *Server) createUser(ctx *gin.Context) {
@@ -54,30 +67,45 @@ func (server *Server) createUser(ctx *gin.Context) {
 
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-
+		return
 	}
 
-	arg := db.CreateUserParams{
+	secretCode, err := util.NewSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
 
-		Username:       req.Username,
-		HashedPassword: hashedPassword,
-		FullName:       req.FullName,
-		Email:          req.Email,
+	arg := db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+
+			Username:       req.Username,
+			HashedPassword: hashedPassword,
+			FullName:       req.FullName,
+			Email:          req.Email,
+		},
+		SecretCode: secretCode,
+		//* if the mail cannot be sent the user is not created, so they can simply sign up again
+		AfterCreate: func(user db.User, verifyEmail db.VerifyEmail) error {
+			return server.sendVerifyEmail(user, verifyEmail)
+		},
 	}
 
-	user, err := server.store.CreateUser(ctx, arg)
+	result, err := server.store.CreateUserTx(ctx, arg)
 
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
 			switch pqErr.Code.Name() {
 			case "unique_violation":
 				ctx.JSON(http.StatusForbidden, errorResponse(err))
+				return
 			}
 		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
 	}
 
-	res := newUserResponse(user)
+	res := newUserResponse(result.User)
 
 	ctx.JSON(http.StatusOK, res)
 
@@ -89,8 +117,19 @@ type loginUserRequest struct {
 }
 
 type loginUserResponse struct {
-	AccessToken string       `json:"access_token"`
-	User        userResponse `json:"user"`
+	SessionID             uuid.UUID    `json:"session_id"`
+	AccessToken           string       `json:"access_token"`
+	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  userResponse `json:"user"`
+}
+
+// ^ returned by login instead of the tokens when the user has totp enabled
+type loginMFARequiredResponse struct {
+	MFARequired       bool      `json:"mfa_required"`
+	MFAToken          string    `json:"mfa_token"`
+	MFATokenExpiresAt time.Time `json:"mfa_token_expires_at"`
 }
 
 func (server *Server) loginUser(ctx *gin.Context) {
@@ -102,6 +141,12 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		return
 	}
 
+	//* the ip limit runs as middleware, the username is only known here
+	if ok, retryAfter := server.loginLimiter.allow("user:" + req.Username); !ok {
+		abortTooManyRequests(ctx, retryAfter, errRateLimited)
+		return
+	}
+
 	user, err := server.store.GetUser(ctx, req.Username)
 
 	if err != nil {
@@ -114,29 +159,190 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		return
 	}
 
+	if !server.checkLoginAllowed(ctx, user) {
+		return
+	}
+
 	//now we check passowrd
 
 	err = util.CheckPassword(req.Password, user.HashedPassword)
 
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		server.recordFailedLogin(ctx, user.Username, err)
+		return
+	}
+
+	if user.DeactivatedAt.Valid {
+		ctx.JSON(http.StatusForbidden, errorResponse(errUserDeactivated))
+		return
+	}
+
+	server.upgradePasswordHash(ctx, user, req.Password)
+
+	//* with totp on the password alone only earns a challenge token,
+	//* the failure count is reset once the second factor is in as well
+	if user.TotpEnabled {
+		mfaToken, mfaPayload, err := server.tokenMaker.CreateToken(
+			user.Username,
+			util.MFAChallengeRole,
+			token.TokenTypeAccess,
+			server.config.MFAChallengeDuration,
+		)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, loginMFARequiredResponse{
+			MFARequired:       true,
+			MFAToken:          mfaToken,
+			MFATokenExpiresAt: mfaPayload.ExpiredAt,
+		})
 		return
 	}
 
-	accessToken, err := server.tokenMaker.CreateToken(
+	if err := server.resetFailedLogins(ctx, user); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp, err := server.createLoginSession(ctx, user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// ! issues the access and refresh tokens and stores the session,
+// ! shared by the password login and the second factor step
+func (server *Server) createLoginSession(ctx *gin.Context, user db.User) (loginUserResponse, error) {
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
 		user.Username,
+		user.Role,
+		token.TokenTypeAccess,
 		server.config.AccessTokenDuration,
 	)
 
 	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	//* the refresh token lives much longer than the access token so it is
+	//* persisted as a session that can be looked up (and blocked) later
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
+		user.Username,
+		user.Role,
+		token.TokenTypeRefresh,
+		server.config.RefreshTokenDuration,
+	)
+
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           refreshPayload.Id,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
+
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	rsp := loginUserResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		User:                  newUserResponse(user),
+	}
+	return rsp, nil
+}
+
+// * hashes from an older algorithm or cost are replaced while the plain password
+// * is at hand. best effort: a failure here must not fail the login, the next one retries
+func (server *Server) upgradePasswordHash(ctx *gin.Context, user db.User, password string) {
+	if !util.PasswordNeedsRehash(user.HashedPassword) {
+		return
+	}
+
+	hashedPassword, err := util.HashedPassword(password)
+	if err != nil {
+		return
+	}
+
+	//* password_changed_at stays as it is, the password itself did not change
+	_ = server.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+		NewHashedPassword: hashedPassword,
+		Username:          user.Username,
+		OldHashedPassword: user.HashedPassword,
+	})
+}
+
+type changePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required,min=10"`
+	NewPassword string `json:"new_password" binding:"required,min=10"`
+}
+
+// ! re-hashes the password and bumps password_changed_at, the auth middleware
+// ! then rejects every token issued before the change (including this one)
+func (server *Server) changePassword(ctx *gin.Context) {
+
+	var req changePasswordRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	user, err := server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	rsp := loginUserResponse{
-		AccessToken: accessToken,
-		User:        newUserResponse(user),
+	if err := util.CheckPassword(req.OldPassword, user.HashedPassword); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
 	}
 
-	ctx.JSON(http.StatusOK, rsp)
+	hashedPassword, err := util.HashedPassword(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	//* the app clock is used so it lines up with the IssuedAt of new tokens
+	user, err = server.store.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+		Username:          user.Username,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	//* refresh tokens are tokens too, so no session may renew after this
+	if err := server.store.BlockUserSessions(ctx, user.Username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
 }
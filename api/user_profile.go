@@ -0,0 +1,136 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/token"
+	"github.com/lib/pq"
+)
+
+var errUserDeactivated = errors.New("user is deactivated")
+
+type userUriRequest struct {
+	Username string `uri:"username" binding:"required"`
+}
+
+// * users manage their own profile, bankers can manage everyone's
+func canManageUser(ctx *gin.Context, username string) bool {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if authPayload.Username == username || authPayload.Role == util.BankerRole {
+		return true
+	}
+
+	err := errors.New("cannot manage another user")
+	ctx.JSON(http.StatusForbidden, errorResponse(err))
+	return false
+}
+
+// ^ pointers so a missing field is left alone instead of being cleared
+type updateUserRequest struct {
+	FullName *string `json:"full_name" binding:"omitempty,min=1"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+}
+
+// ! partial profile update, a new email has to be verified again
+func (server *Server) updateUser(ctx *gin.Context) {
+
+	var uri userUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req updateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !canManageUser(ctx, uri.Username) {
+		return
+	}
+
+	secretCode, err := util.NewSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	arg := db.UpdateUserTxParams{
+		UpdateUserParams: db.UpdateUserParams{
+			Username: uri.Username,
+		},
+		SecretCode: secretCode,
+		AfterUpdate: func(user db.User, verifyEmail db.VerifyEmail) error {
+			return server.sendVerifyEmail(user, verifyEmail)
+		},
+	}
+	if req.FullName != nil {
+		arg.FullName = sql.NullString{String: *req.FullName, Valid: true}
+	}
+	if req.Email != nil {
+		arg.Email = sql.NullString{String: *req.Email, Valid: true}
+	}
+
+	result, err := server.store.UpdateUserTx(ctx, arg)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code.Name() == "unique_violation" {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(result.User))
+}
+
+// ! soft deactivation: the user row and the accounts stay for the records,
+// ! but the user cannot log in any more and the accounts become read-only
+func (server *Server) deactivateUser(ctx *gin.Context) {
+
+	var uri userUriRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !canManageUser(ctx, uri.Username) {
+		return
+	}
+
+	user, err := server.store.DeactivateUser(ctx, uri.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err := fmt.Errorf("user %q not found or already deactivated", uri.Username)
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	//* the auth middleware refuses deactivated users anyway, this also stops the refresh tokens
+	if err := server.store.BlockUserSessions(ctx, user.Username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.revocations.RevokeAll(ctx, user.Username, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newUserResponse(user))
+}
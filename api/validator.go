@@ -15,3 +15,11 @@ var validCurrency validator.Func = func(feildlevel validator.FieldLevel) bool {
 	}
 	return false
 }
+
+// ^ used with dive so every scope of an api key request is checked
+var validScope validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if scope, ok := fieldLevel.Field().Interface().(string); ok {
+		return util.IsSupportedScope(scope)
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+)
+
+// * builds the verification link and mails it to the new user
+func (server *Server) sendVerifyEmail(user db.User, verifyEmail db.VerifyEmail) error {
+	query := url.Values{}
+	query.Set("email_id", fmt.Sprint(verifyEmail.ID))
+	query.Set("secret_code", verifyEmail.SecretCode)
+	verifyURL := server.config.AppBaseURL + "/verify_email?" + query.Encode()
+
+	subject := "Welcome to Simple Bank"
+	content := fmt.Sprintf(`Hello %s,<br/>
+	Thank you for registering with us!<br/>
+	Please <a href="%s">click here</a> to verify your email address.<br/>
+	`, html.EscapeString(user.FullName), html.EscapeString(verifyURL))
+
+	return server.mailer.SendEmail(subject, content, []string{verifyEmail.Email})
+}
+
+type verifyEmailRequest struct {
+	EmailId    int64  `form:"email_id" binding:"required,min=1"`
+	SecretCode string `form:"secret_code" binding:"required"`
+}
+
+type verifyEmailResponse struct {
+	IsVerified bool `json:"is_verified"`
+}
+
+// ! marks the email as verified when the link from the mail is opened
+func (server *Server) verifyEmail(ctx *gin.Context) {
+
+	var req verifyEmailRequest
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	result, err := server.store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{
+		EmailId:    req.EmailId,
+		SecretCode: req.SecretCode,
+	})
+	if err != nil {
+		//* unknown, already used and expired codes all look the same
+		if err == sql.ErrNoRows {
+			err := errors.New("invalid or expired verification link")
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, verifyEmailResponse{IsVerified: result.User.IsEmailVerified})
+}
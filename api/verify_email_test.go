@@ -0,0 +1,230 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mockdb "github.com/itsadijmbt/simple_bank/db/mock"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/mail"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestVerifyEmailAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.IsEmailVerified = true
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "OK",
+			query: "email_id=7&secret_code=code",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Eq(db.VerifyEmailTxParams{EmailId: 7, SecretCode: "code"})).
+					Times(1).
+					Return(db.VerifyEmailTxResult{User: user}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp verifyEmailResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, rsp.IsVerified)
+			},
+		},
+		{
+			name:  "InvalidOrExpiredCode",
+			query: "email_id=7&secret_code=wrong",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:  "InternalError",
+			query: "email_id=7&secret_code=code",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.VerifyEmailTxResult{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:  "MissingSecretCode",
+			query: "email_id=7",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := NewTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/verify_email?"+tc.query, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+type failingSender struct{}
+
+func (failingSender) SendEmail(subject string, content string, to []string) error {
+	return errors.New("smtp is down")
+}
+
+func TestCreateUserVerifyEmail(t *testing.T) {
+	user, password := randomUser(t)
+
+	body, err := json.Marshal(gin.H{
+		"username":  user.Username,
+		"password":  password,
+		"full_name": user.FullName,
+		"email":     user.Email,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		mailer        func(outbox *bytes.Buffer) mail.EmailSender
+		checkResponse func(recorder *httptest.ResponseRecorder, outbox *bytes.Buffer)
+	}{
+		{
+			name: "MailSent",
+			mailer: func(outbox *bytes.Buffer) mail.EmailSender {
+				return mail.NewLogSender(outbox)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, outbox *bytes.Buffer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, outbox.String(), "To: "+user.Email)
+				require.Contains(t, outbox.String(), "https://bank.test/verify_email?email_id=42&amp;secret_code=")
+			},
+		},
+		{
+			//* the transaction rolls back, nobody is left with an unverifiable account
+			name: "MailFails",
+			mailer: func(outbox *bytes.Buffer) mail.EmailSender {
+				return failingSender{}
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, outbox *bytes.Buffer) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				CreateUserTx(gomock.Any(), gomock.Any()).
+				Times(1).
+				DoAndReturn(func(_ context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+					verifyEmail := db.VerifyEmail{ID: 42, Username: user.Username, Email: user.Email, SecretCode: arg.SecretCode}
+					if err := arg.AfterCreate(user, verifyEmail); err != nil {
+						return db.CreateUserTxResult{}, err
+					}
+					return db.CreateUserTxResult{User: user, VerifyEmail: verifyEmail}, nil
+				})
+
+			var outbox bytes.Buffer
+			server := NewTestServer(t, store)
+			server.config.AppBaseURL = "https://bank.test"
+			server.mailer = tc.mailer(&outbox)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder, &outbox)
+		})
+	}
+}
+
+// ^ accounts and transfers stay closed until the email is verified
+func TestUnverifiedEmailBlocked(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(2).
+		Return(user, nil)
+	store.EXPECT().
+		CreateAccount(gomock.Any(), gomock.Any()).
+		Times(0)
+	store.EXPECT().
+		TransferTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	server := NewTestServer(t, store)
+
+	requests := map[string]gin.H{
+		"/accounts":  {"currency": util.USD},
+		"/transfers": {"from_account_id": 1, "to_account_id": 2, "amount": 10, "currency": util.USD},
+	}
+
+	for url, body := range requests {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+		require.NoError(t, err)
+		addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusForbidden, recorder.Code, fmt.Sprint(url))
+	}
+}
@@ -0,0 +1,19 @@
+ALTER TABLE "users" ADD COLUMN "totp_secret" bytea;
+
+ALTER TABLE "users" ADD COLUMN "totp_enabled" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX ON "recovery_codes" ("username", "code_hash");
+
+COMMENT ON COLUMN "users"."totp_secret" IS 'AES-GCM encrypted TOTP secret';
+
+COMMENT ON COLUMN "recovery_codes"."code_hash" IS 'sha256 of the recovery code';
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
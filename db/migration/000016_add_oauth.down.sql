@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "oauth_codes";
+DROP TABLE IF EXISTS "clients";
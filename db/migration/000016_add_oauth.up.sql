@@ -0,0 +1,35 @@
+CREATE TABLE "clients" (
+  "id" varchar PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "name" varchar NOT NULL,
+  "secret_hash" varchar NOT NULL DEFAULT '',
+  "redirect_uris" varchar[] NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "oauth_codes" (
+  "code_hash" varchar PRIMARY KEY,
+  "client_id" varchar NOT NULL,
+  "username" varchar NOT NULL,
+  "redirect_uri" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "code_challenge" varchar NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL
+);
+
+CREATE INDEX ON "clients" ("owner");
+
+COMMENT ON COLUMN "clients"."secret_hash" IS 'sha256 of the client secret, empty for public clients';
+
+COMMENT ON COLUMN "clients"."scopes" IS 'the most a client may ask for';
+
+COMMENT ON COLUMN "oauth_codes"."code_challenge" IS 'PKCE S256 challenge';
+
+ALTER TABLE "clients" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "oauth_codes" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "oauth_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,15 @@
+CREATE TABLE "idempotency_keys" (
+  "owner" varchar NOT NULL,
+  "idempotency_key" varchar NOT NULL,
+  "request_hash" varchar NOT NULL,
+  "response_status" int NOT NULL,
+  "response_body" bytea NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("owner", "idempotency_key")
+);
+
+COMMENT ON COLUMN "idempotency_keys"."idempotency_key" IS 'value of the Idempotency-Key header, unique per user';
+
+COMMENT ON COLUMN "idempotency_keys"."request_hash" IS 'sha256 of the request body the key was first used with';
+
+ALTER TABLE "idempotency_keys" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
@@ -0,0 +1,31 @@
+CREATE TABLE "exchange_rates" (
+  "from_currency" varchar NOT NULL,
+  "to_currency" varchar NOT NULL,
+  "rate" double precision NOT NULL,
+  "spread_bps" int NOT NULL DEFAULT 0,
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("from_currency", "to_currency"),
+  CONSTRAINT "exchange_rates_rate_check" CHECK ("rate" > 0),
+  CONSTRAINT "exchange_rates_spread_bps_check" CHECK ("spread_bps" >= 0 AND "spread_bps" < 10000)
+);
+
+COMMENT ON COLUMN "exchange_rates"."rate" IS 'units of to_currency for one unit of from_currency';
+
+COMMENT ON COLUMN "exchange_rates"."spread_bps" IS 'basis points kept by the bank on conversion';
+
+-- existing transfers were all between accounts of the same currency
+ALTER TABLE "transfers" ADD COLUMN "to_amount" bigint;
+
+UPDATE "transfers" SET "to_amount" = "amount";
+
+ALTER TABLE "transfers" ALTER COLUMN "to_amount" SET NOT NULL;
+
+ALTER TABLE "transfers" ADD COLUMN "exchange_rate" double precision NOT NULL DEFAULT 1;
+
+ALTER TABLE "transfers" ADD COLUMN "spread_bps" int NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN "transfers"."to_amount" IS 'amount credited, in the currency of the destination account';
+
+COMMENT ON COLUMN "transfers"."exchange_rate" IS 'destination units per source unit, 1 for same-currency transfers';
+
+COMMENT ON COLUMN "transfers"."spread_bps" IS 'spread taken on the conversion, in basis points';
@@ -0,0 +1,32 @@
+CREATE TABLE "scheduled_transfers" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "schedule" varchar NOT NULL,
+  "starts_at" timestamptz NOT NULL,
+  "ends_at" timestamptz,
+  "next_run_at" timestamptz,
+  "last_run_at" timestamptz,
+  "last_error" varchar NOT NULL DEFAULT '',
+  "locked_until" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "scheduled_transfers_amount_check" CHECK ("amount" > 0)
+);
+
+CREATE INDEX ON "scheduled_transfers" ("owner");
+
+CREATE INDEX ON "scheduled_transfers" ("next_run_at") WHERE "next_run_at" IS NOT NULL;
+
+COMMENT ON COLUMN "scheduled_transfers"."schedule" IS 'five field cron expression or @every <duration>';
+
+COMMENT ON COLUMN "scheduled_transfers"."next_run_at" IS 'null once the schedule has ended';
+
+COMMENT ON COLUMN "scheduled_transfers"."locked_until" IS 'lease of the scheduler replica running it';
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
@@ -0,0 +1,28 @@
+CREATE TABLE "transfer_limits" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar UNIQUE,
+  "account_id" bigint UNIQUE,
+  "max_amount" bigint,
+  "daily_amount" bigint,
+  "daily_count" int,
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "transfer_limits_scope_check" CHECK (("owner" IS NULL) <> ("account_id" IS NULL)),
+  CONSTRAINT "transfer_limits_values_check" CHECK ("max_amount" > 0 AND "daily_amount" > 0 AND "daily_count" > 0)
+);
+
+COMMENT ON COLUMN "transfer_limits"."owner" IS 'set for a user limit, a null field falls back to the configured default';
+
+COMMENT ON COLUMN "transfer_limits"."account_id" IS 'set for an account limit, a null field means no limit';
+
+COMMENT ON COLUMN "transfer_limits"."max_amount" IS 'largest single transfer';
+
+COMMENT ON COLUMN "transfer_limits"."daily_amount" IS 'total sent in any 24 hours';
+
+COMMENT ON COLUMN "transfer_limits"."daily_count" IS 'transfers sent in any 24 hours';
+
+ALTER TABLE "transfer_limits" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "transfer_limits" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+-- the limits sum a sender's recent transfers
+CREATE INDEX ON "transfers" ("from_account_id", "created_at");
@@ -0,0 +1,37 @@
+-- money enters and leaves the bank through one clearing account per currency, owned by a user nobody can log in as
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email")
+VALUES ('system', '', 'Clearing', 'system@simple-bank.invalid');
+
+INSERT INTO "accounts" ("owner", "balance", "currency")
+SELECT 'system', 0, "currency"
+FROM (
+  SELECT unnest(ARRAY['USD', 'EUR', 'CAD', 'INR']) AS "currency"
+  UNION
+  SELECT DISTINCT "currency" FROM "accounts"
+) AS "currencies";
+
+-- clearing accounts mirror everything deposited, so they run as negative as needed
+ALTER TABLE "accounts" DROP CONSTRAINT "accounts_balance_check";
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_balance_check" CHECK ("owner" = 'system' OR "balance" >= -"overdraft_limit");
+
+-- balances set outside of entries so far get an opening entry against clearing, so sum(entries) matches them
+WITH "openings" AS (
+  SELECT a."id", a."currency", a."balance" - COALESCE(SUM(e."amount"), 0) AS "amount"
+  FROM "accounts" a
+  LEFT JOIN "entries" e ON e."account_id" = a."id"
+  WHERE a."owner" <> 'system'
+  GROUP BY a."id"
+  HAVING a."balance" <> COALESCE(SUM(e."amount"), 0)
+)
+INSERT INTO "entries" ("account_id", "amount")
+SELECT "id", "amount" FROM "openings"
+UNION ALL
+SELECT c."id", -SUM(o."amount")
+FROM "openings" o
+JOIN "accounts" c ON c."owner" = 'system' AND c."currency" = o."currency"
+GROUP BY c."id";
+
+UPDATE "accounts" c
+SET "balance" = (SELECT COALESCE(SUM("amount"), 0) FROM "entries" WHERE "account_id" = c."id")
+WHERE c."owner" = 'system';
@@ -0,0 +1,33 @@
+ALTER TABLE "entries" ADD COLUMN "transfer_id" bigint;
+
+ALTER TABLE "entries" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
+
+CREATE INDEX ON "entries" ("transfer_id");
+
+COMMENT ON COLUMN "entries"."transfer_id" IS 'the transfer that posted it, null for deposits and withdrawals';
+
+-- a transfer and its entries were written in one transaction, so they share created_at
+UPDATE "entries" e
+SET "transfer_id" = t."id"
+FROM "transfers" t
+WHERE e."created_at" = t."created_at"
+  AND ((e."account_id" = t."from_account_id" AND e."amount" = -t."amount")
+    OR (e."account_id" = t."to_account_id" AND e."amount" = t."to_amount"));
+
+CREATE TABLE "reconciliation_runs" (
+  "id" bigserial PRIMARY KEY,
+  "accounts_checked" bigint NOT NULL,
+  "transfers_checked" bigint NOT NULL,
+  "drifted_accounts" integer NOT NULL,
+  "orphaned_transfers" integer NOT NULL,
+  "unbalanced_transfers" integer NOT NULL,
+  "findings" jsonb NOT NULL,
+  "started_at" timestamptz NOT NULL,
+  "finished_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+COMMENT ON COLUMN "reconciliation_runs"."drifted_accounts" IS 'accounts whose balance is not the sum of their entries';
+
+COMMENT ON COLUMN "reconciliation_runs"."orphaned_transfers" IS 'transfers without any entries';
+
+COMMENT ON COLUMN "reconciliation_runs"."unbalanced_transfers" IS 'transfers with entries that are not exactly one matching debit and one matching credit';
@@ -0,0 +1,39 @@
+ALTER TABLE "entries" ADD COLUMN "prev_hash" bytea;
+
+ALTER TABLE "entries" ADD COLUMN "hash" bytea;
+
+COMMENT ON COLUMN "entries"."prev_hash" IS 'hash of the previous entry of the same account, null for its first';
+
+COMMENT ON COLUMN "entries"."hash" IS 'sha256 over prev_hash and the entry, see entryHash; set in the transaction that inserts the entry';
+
+-- the chain is walked per account in id order
+CREATE INDEX ON "entries" ("account_id", "id");
+
+-- chain the existing entries, the encoding must stay byte for byte the one of entryHash in db/sqlc/entry_hash.go
+DO $$
+DECLARE
+  e record;
+  prev bytea;
+  last_account bigint;
+BEGIN
+  FOR e IN SELECT * FROM "entries" ORDER BY "account_id", "id" LOOP
+    IF last_account IS DISTINCT FROM e."account_id" THEN
+      prev := NULL;
+      last_account := e."account_id";
+    END IF;
+
+    UPDATE "entries"
+    SET "prev_hash" = prev,
+        "hash" = sha256(
+          COALESCE(prev, ''::bytea)
+          || int8send(e."id")
+          || int8send(e."account_id")
+          || int8send(e."amount")
+          || int8send(COALESCE(e."transfer_id", 0))
+          || int8send(extract(epoch FROM date_trunc('second', e."created_at"))::bigint * 1000000
+                      + extract(microseconds FROM e."created_at")::bigint % 1000000)
+        )
+    WHERE "id" = e."id"
+    RETURNING "hash" INTO prev;
+  END LOOP;
+END $$;
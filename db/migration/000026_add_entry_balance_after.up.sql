@@ -0,0 +1,17 @@
+ALTER TABLE "entries" ADD COLUMN "balance_after" bigint NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN "entries"."balance_after" IS 'balance of the account once the entry was posted, not covered by hash';
+
+-- walk back from today's balance so the newest entry of each account matches it
+UPDATE "entries" e
+SET "balance_after" = r."balance_after"
+FROM (
+  SELECT
+    e."id",
+    a."balance"
+      - SUM(e."amount") OVER (PARTITION BY e."account_id")
+      + SUM(e."amount") OVER (PARTITION BY e."account_id" ORDER BY e."id") AS "balance_after"
+  FROM "entries" e
+  JOIN "accounts" a ON a."id" = e."account_id"
+) r
+WHERE e."id" = r."id";
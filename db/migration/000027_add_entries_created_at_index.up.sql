@@ -0,0 +1,2 @@
+-- statements select an account's entries by date
+CREATE INDEX ON "entries" ("account_id", "created_at");
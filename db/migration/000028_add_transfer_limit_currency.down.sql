@@ -0,0 +1,12 @@
+ALTER TABLE "transfer_limits" DROP CONSTRAINT IF EXISTS "transfer_limits_currency_check";
+
+ALTER TABLE "transfer_limits" DROP CONSTRAINT IF EXISTS "transfer_limits_owner_currency_key";
+
+-- one limit per user again, the oldest one is kept
+DELETE FROM "transfer_limits" l
+WHERE l."owner" IS NOT NULL
+  AND l."id" <> (SELECT MIN("id") FROM "transfer_limits" WHERE "owner" = l."owner");
+
+ALTER TABLE "transfer_limits" ADD CONSTRAINT "transfer_limits_owner_key" UNIQUE ("owner");
+
+ALTER TABLE "transfer_limits" DROP COLUMN IF EXISTS "currency";
@@ -0,0 +1,19 @@
+-- amounts in different currencies can't be summed, user limits are kept per currency
+ALTER TABLE "transfer_limits" ADD COLUMN "currency" varchar;
+
+ALTER TABLE "transfer_limits" DROP CONSTRAINT "transfer_limits_owner_key";
+
+-- an existing user limit is copied to every currency the user holds
+INSERT INTO "transfer_limits" ("owner", "currency", "max_amount", "daily_amount", "daily_count", "updated_at")
+SELECT DISTINCT l."owner", a."currency", l."max_amount", l."daily_amount", l."daily_count", l."updated_at"
+FROM "transfer_limits" l
+JOIN "accounts" a ON a."owner" = l."owner";
+
+DELETE FROM "transfer_limits"
+WHERE "owner" IS NOT NULL AND "currency" IS NULL;
+
+ALTER TABLE "transfer_limits" ADD CONSTRAINT "transfer_limits_owner_currency_key" UNIQUE ("owner", "currency");
+
+ALTER TABLE "transfer_limits" ADD CONSTRAINT "transfer_limits_currency_check" CHECK (("owner" IS NULL) = ("currency" IS NULL));
+
+COMMENT ON COLUMN "transfer_limits"."currency" IS 'set with owner, a user limit covers their accounts in this currency';
@@ -0,0 +1,31 @@
+COMMENT ON COLUMN "entries"."balance_after" IS 'balance of the account once the entry was posted, part of hash';
+
+-- re-chain every entry with balance_after appended, byte for byte the encoding of entryHash in db/sqlc/entry_hash.go
+DO $$
+DECLARE
+  e record;
+  prev bytea;
+  last_account bigint;
+BEGIN
+  FOR e IN SELECT * FROM "entries" ORDER BY "account_id", "id" LOOP
+    IF last_account IS DISTINCT FROM e."account_id" THEN
+      prev := NULL;
+      last_account := e."account_id";
+    END IF;
+
+    UPDATE "entries"
+    SET "prev_hash" = prev,
+        "hash" = sha256(
+          COALESCE(prev, ''::bytea)
+          || int8send(e."id")
+          || int8send(e."account_id")
+          || int8send(e."amount")
+          || int8send(COALESCE(e."transfer_id", 0))
+          || int8send(extract(epoch FROM date_trunc('second', e."created_at"))::bigint * 1000000
+                      + extract(microseconds FROM e."created_at")::bigint % 1000000)
+          || int8send(e."balance_after")
+        )
+    WHERE "id" = e."id"
+    RETURNING "hash" INTO prev;
+  END LOOP;
+END $$;
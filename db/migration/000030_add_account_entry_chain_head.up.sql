@@ -0,0 +1,11 @@
+ALTER TABLE "accounts" ADD COLUMN "entry_chain_head" bytea;
+
+COMMENT ON COLUMN "accounts"."entry_chain_head" IS 'hash of the newest entry of the account, moved in the transaction that appends it';
+
+UPDATE "accounts" a
+SET "entry_chain_head" = (
+  SELECT e."hash" FROM "entries" e
+  WHERE e."account_id" = a."id"
+  ORDER BY e."id" DESC
+  LIMIT 1
+);
@@ -23,6 +23,16 @@ WHERE id = $1
 LIMIT 1
 FOR NO KEY UPDATE;
 
+-- name: GetClearingAccount :one
+SELECT *
+FROM accounts
+WHERE owner = 'system' AND currency = $1
+LIMIT 1;
+
+-- name: ListAccountIDs :many
+SELECT id FROM accounts
+ORDER BY id;
+
 -- name: ListAccounts :many
 SELECT *
 FROM accounts
@@ -43,5 +53,22 @@ SET balance = balance + sqlc.arg(amount)
 WHERE id = sqlc.arg(id)
 RETURNING *;
 
+-- name: SetAccountEntryChainHead :exec
+UPDATE accounts
+SET entry_chain_head = $2
+WHERE id = $1;
+
 -- name: DeleteAccount :exec
 DELETE FROM accounts WHERE id = $1;
+
+-- name: UpdateAccountFrozen :one
+UPDATE accounts
+SET is_frozen = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = $2
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,38 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (
+    owner,
+    name,
+    prefix,
+    key_hash,
+    scopes,
+    expired_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetApiKey :one
+SELECT * FROM api_keys
+WHERE id = $1 LIMIT 1;
+
+-- name: GetApiKeyByPrefix :one
+SELECT * FROM api_keys
+WHERE prefix = $1 LIMIT 1;
+
+-- name: ListApiKeys :many
+SELECT * FROM api_keys
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
+-- name: UpdateApiKey :one
+UPDATE api_keys
+SET
+  name = COALESCE(sqlc.narg(name), name),
+  scopes = COALESCE(sqlc.narg(scopes)::varchar[], scopes)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteApiKey :exec
+DELETE FROM api_keys
+WHERE id = $1;
@@ -0,0 +1,19 @@
+-- name: CreateClient :one
+INSERT INTO clients (
+    id,
+    owner,
+    name,
+    secret_hash,
+    redirect_uris,
+    scopes
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetClient :one
+SELECT * FROM clients
+WHERE id = $1 LIMIT 1;
+
+-- name: DeleteClient :exec
+DELETE FROM clients
+WHERE id = $1;
@@ -1,9 +1,12 @@
 -- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  transfer_id,
+  balance_after,
+  created_at
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4, clock_timestamp()
 ) RETURNING *;
 
 -- name: GetEntry :one
@@ -15,4 +18,59 @@ SELECT * FROM entries
 WHERE account_id = $1
 ORDER BY id
 LIMIT $2
-OFFSET $3;
\ No newline at end of file
+OFFSET $3;
+
+-- name: SetEntryHash :one
+UPDATE entries
+SET prev_hash = $2,
+    hash = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: ListEntryChain :many
+SELECT * FROM entries
+WHERE account_id = sqlc.arg(account_id) AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+
+-- name: GetStatementSummary :one
+SELECT
+    COALESCE((
+        SELECT o.balance_after FROM entries o
+        WHERE o.account_id = sqlc.arg(account_id) AND o.created_at < sqlc.arg(from_time)
+        ORDER BY o.created_at DESC, o.id DESC
+        LIMIT 1
+    ), 0)::bigint AS opening_balance,
+    COALESCE((
+        SELECT c.balance_after FROM entries c
+        WHERE c.account_id = sqlc.arg(account_id) AND c.created_at < sqlc.arg(to_time)
+        ORDER BY c.created_at DESC, c.id DESC
+        LIMIT 1
+    ), 0)::bigint AS closing_balance,
+    COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0)::bigint AS total_credits,
+    COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0)::bigint AS total_debits,
+    COUNT(*)::int AS entry_count
+FROM entries
+WHERE account_id = sqlc.arg(account_id)
+    AND created_at >= sqlc.arg(from_time)
+    AND created_at < sqlc.arg(to_time);
+
+-- name: ListStatementEntries :many
+SELECT
+    e.id,
+    e.amount,
+    e.balance_after,
+    e.created_at,
+    e.transfer_id,
+    c.id AS counterparty_account_id,
+    c.owner AS counterparty_owner
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+LEFT JOIN accounts c ON c.id = CASE
+    WHEN t.from_account_id = e.account_id THEN t.to_account_id
+    ELSE t.from_account_id
+END
+WHERE e.account_id = sqlc.arg(account_id)
+    AND e.created_at >= sqlc.arg(from_time)
+    AND e.created_at < sqlc.arg(to_time)
+ORDER BY e.created_at, e.id;
@@ -0,0 +1,14 @@
+-- name: CreateIdempotencyKey :one
+INSERT INTO idempotency_keys (
+    owner,
+    idempotency_key,
+    request_hash,
+    response_status,
+    response_body
+) VALUES (
+    $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE owner = $1 AND idempotency_key = $2 LIMIT 1;
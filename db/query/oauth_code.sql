@@ -0,0 +1,20 @@
+-- name: CreateOauthCode :one
+INSERT INTO oauth_codes (
+    code_hash,
+    client_id,
+    username,
+    redirect_uri,
+    scopes,
+    code_challenge,
+    expired_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: UseOauthCode :one
+UPDATE oauth_codes
+SET is_used = true
+WHERE code_hash = $1
+  AND is_used = false
+  AND expired_at > now()
+RETURNING *;
@@ -0,0 +1,31 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (
+    id,
+    username,
+    expires_at
+) VALUES (
+    $1, $2, $3
+) ON CONFLICT (id) DO NOTHING;
+
+-- name: IsTokenRevoked :one
+SELECT EXISTS (
+    SELECT 1 FROM revoked_tokens
+    WHERE id = $1
+);
+
+-- name: RevokeUserTokens :exec
+INSERT INTO user_token_revocations (
+    username,
+    revoked_before
+) VALUES (
+    $1, $2
+) ON CONFLICT (username) DO UPDATE
+SET revoked_before = EXCLUDED.revoked_before;
+
+-- name: IsUserTokenRevoked :one
+SELECT EXISTS (
+    SELECT 1 FROM user_token_revocations
+    WHERE username = sqlc.arg(username)
+      AND revoked_before > sqlc.arg(issued_at)
+);
+
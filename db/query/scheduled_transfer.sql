@@ -0,0 +1,50 @@
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+    owner,
+    from_account_id,
+    to_account_id,
+    amount,
+    schedule,
+    starts_at,
+    ends_at,
+    next_run_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1 LIMIT 1;
+
+-- name: ListScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE owner = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
+-- name: DeleteScheduledTransfer :exec
+DELETE FROM scheduled_transfers
+WHERE id = $1;
+
+-- name: ClaimDueScheduledTransfers :many
+UPDATE scheduled_transfers
+SET locked_until = sqlc.arg(locked_until)
+WHERE id IN (
+    SELECT id FROM scheduled_transfers
+    WHERE next_run_at <= now()
+      AND (locked_until IS NULL OR locked_until < now())
+    ORDER BY next_run_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: FinishScheduledTransferRun :one
+UPDATE scheduled_transfers
+SET next_run_at = $2,
+    last_run_at = now(),
+    last_error = $3,
+    locked_until = NULL
+WHERE id = $1
+RETURNING *;
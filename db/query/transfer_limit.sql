@@ -0,0 +1,40 @@
+-- name: GetAccountTransferLimit :one
+SELECT * FROM transfer_limits
+WHERE account_id = $1 LIMIT 1;
+
+-- name: GetUserTransferLimit :one
+SELECT * FROM transfer_limits
+WHERE owner = $1 AND currency = $2 LIMIT 1;
+
+-- name: UpsertAccountTransferLimit :one
+INSERT INTO transfer_limits (
+    account_id,
+    max_amount,
+    daily_amount,
+    daily_count
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (account_id) DO UPDATE
+SET max_amount = EXCLUDED.max_amount,
+    daily_amount = EXCLUDED.daily_amount,
+    daily_count = EXCLUDED.daily_count,
+    updated_at = now()
+RETURNING *;
+
+-- name: UpsertUserTransferLimit :one
+INSERT INTO transfer_limits (
+    owner,
+    currency,
+    max_amount,
+    daily_amount,
+    daily_count
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+ON CONFLICT (owner, currency) DO UPDATE
+SET max_amount = EXCLUDED.max_amount,
+    daily_amount = EXCLUDED.daily_amount,
+    daily_count = EXCLUDED.daily_count,
+    updated_at = now()
+RETURNING *;
@@ -2,15 +2,40 @@
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  to_amount,
+  exchange_rate,
+  spread_bps,
+  reversal_of
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4, $5, $6, $7
 ) RETURNING *;
 
+-- name: GetAccountTransferTotals :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS total, COUNT(*)::int AS count
+FROM transfers
+WHERE from_account_id = $1
+  AND reversal_of IS NULL
+  AND created_at > now() - interval '24 hours';
+
 -- name: GetTransfer :one
 SELECT * FROM transfers
 WHERE id = $1 LIMIT 1;
 
+-- name: GetTransferForUpdate :one
+SELECT * FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: GetUserTransferTotals :one
+SELECT COALESCE(SUM(t.amount), 0)::bigint AS total, COUNT(*)::int AS count
+FROM transfers t
+JOIN accounts a ON a.id = t.from_account_id
+WHERE a.owner = $1
+  AND a.currency = $2
+  AND t.reversal_of IS NULL
+  AND t.created_at > now() - interval '24 hours';
+
 -- name: ListTransfers :many
 SELECT *
 FROM transfers
@@ -19,3 +44,10 @@ WHERE from_account_id = $1
 ORDER BY id
 LIMIT  $2
 OFFSET $3;
+
+-- name: UpdateTransferReversal :one
+UPDATE transfers
+SET reversed_amount = $2,
+    status = $3
+WHERE id = $1
+RETURNING *;
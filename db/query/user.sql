@@ -12,3 +12,83 @@ INSERT INTO users(
 SELECT * FROM users
 WHERE username = $1
 LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1
+LIMIT 1;
+
+-- name: GetUserForUpdate :one
+SELECT * FROM users
+WHERE username = $1
+LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2,
+    password_changed_at = $3
+WHERE username = $1
+RETURNING *;
+
+-- name: UpdateUserTOTP :one
+UPDATE users
+SET totp_secret = $2,
+    totp_enabled = $3
+WHERE username = $1
+RETURNING *;
+
+-- name: RecordFailedLogin :one
+UPDATE users
+SET failed_login_attempts = failed_login_attempts + 1,
+    locked_until = now() + interval '1 microsecond' * (
+      CASE
+        WHEN sqlc.arg(max_attempts)::int > 0 AND failed_login_attempts + 1 >= sqlc.arg(max_attempts)::int
+          THEN sqlc.arg(lockout_micros)::bigint
+        WHEN sqlc.arg(backoff_base_micros)::bigint <= 0
+          THEN 0
+        WHEN sqlc.arg(lockout_micros)::bigint > 0
+          THEN LEAST(sqlc.arg(backoff_base_micros)::bigint << LEAST(failed_login_attempts, 20), sqlc.arg(lockout_micros)::bigint)
+        ELSE sqlc.arg(backoff_base_micros)::bigint << LEAST(failed_login_attempts, 20)
+      END
+    )
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: UnlockUser :one
+UPDATE users
+SET failed_login_attempts = 0,
+    locked_until = '0001-01-01 00:00:00Z'
+WHERE username = $1
+RETURNING *;
+
+-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = sqlc.arg(new_hashed_password)
+WHERE username = sqlc.arg(username)
+  AND hashed_password = sqlc.arg(old_hashed_password);
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = true
+WHERE username = $1 AND email = $2
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users
+SET
+  full_name = COALESCE(sqlc.narg(full_name), full_name),
+  email = COALESCE(sqlc.narg(email), email),
+  is_email_verified = CASE
+    WHEN sqlc.narg(email) IS NULL OR sqlc.narg(email) = email THEN is_email_verified
+    ELSE false
+  END
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: DeactivateUser :one
+UPDATE users
+SET deactivated_at = now()
+WHERE username = $1
+  AND deactivated_at IS NULL
+RETURNING *;
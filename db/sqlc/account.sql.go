@@ -13,7 +13,7 @@ const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + $1
 WHERE id = $2
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
 `
 
 type AddAccountBalanceParams struct {
@@ -30,6 +30,9 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.OverdraftLimit,
+		&i.EntryChainHead,
 	)
 	return i, err
 }
@@ -44,7 +47,7 @@ INSERT INTO accounts (
     $2,
     $3
 )
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
 `
 
 type CreateAccountParams struct {
@@ -62,6 +65,9 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.OverdraftLimit,
+		&i.EntryChainHead,
 	)
 	return i, err
 }
@@ -76,7 +82,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at
+SELECT id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
 FROM accounts
 WHERE id = $1
 LIMIT 1
@@ -91,12 +97,15 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.OverdraftLimit,
+		&i.EntryChainHead,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at
+SELECT id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
 FROM accounts
 WHERE id = $1
 LIMIT 1
@@ -112,12 +121,66 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.OverdraftLimit,
+		&i.EntryChainHead,
 	)
 	return i, err
 }
 
+const getClearingAccount = `-- name: GetClearingAccount :one
+SELECT id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
+FROM accounts
+WHERE owner = 'system' AND currency = $1
+LIMIT 1
+`
+
+func (q *Queries) GetClearingAccount(ctx context.Context, currency string) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getClearingAccount, currency)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.OverdraftLimit,
+		&i.EntryChainHead,
+	)
+	return i, err
+}
+
+const listAccountIDs = `-- name: ListAccountIDs :many
+SELECT id FROM accounts
+ORDER BY id
+`
+
+func (q *Queries) ListAccountIDs(ctx context.Context) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, owner, balance, currency, created_at
+SELECT id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
 FROM accounts
 WHERE owner = $1
 ORDER BY id
@@ -146,6 +209,9 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.IsFrozen,
+			&i.OverdraftLimit,
+			&i.EntryChainHead,
 		); err != nil {
 			return nil, err
 		}
@@ -160,11 +226,27 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 	return items, nil
 }
 
+const setAccountEntryChainHead = `-- name: SetAccountEntryChainHead :exec
+UPDATE accounts
+SET entry_chain_head = $2
+WHERE id = $1
+`
+
+type SetAccountEntryChainHeadParams struct {
+	ID             int64  `json:"id"`
+	EntryChainHead []byte `json:"entry_chain_head"`
+}
+
+func (q *Queries) SetAccountEntryChainHead(ctx context.Context, arg SetAccountEntryChainHeadParams) error {
+	_, err := q.db.ExecContext(ctx, setAccountEntryChainHead, arg.ID, arg.EntryChainHead)
+	return err
+}
+
 const updateAccount = `-- name: UpdateAccount :one
 UPDATE accounts
 SET balance = $2
 WHERE id = $1
-RETURNING id, owner, balance, currency, created_at
+RETURNING id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
 `
 
 type UpdateAccountParams struct {
@@ -181,6 +263,65 @@ func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.OverdraftLimit,
+		&i.EntryChainHead,
+	)
+	return i, err
+}
+
+const updateAccountFrozen = `-- name: UpdateAccountFrozen :one
+UPDATE accounts
+SET is_frozen = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
+`
+
+type UpdateAccountFrozenParams struct {
+	ID       int64 `json:"id"`
+	IsFrozen bool  `json:"is_frozen"`
+}
+
+func (q *Queries) UpdateAccountFrozen(ctx context.Context, arg UpdateAccountFrozenParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountFrozen, arg.ID, arg.IsFrozen)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.OverdraftLimit,
+		&i.EntryChainHead,
+	)
+	return i, err
+}
+
+const updateAccountOverdraftLimit = `-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = $2
+WHERE id = $1
+RETURNING id, owner, balance, currency, created_at, is_frozen, overdraft_limit, entry_chain_head
+`
+
+type UpdateAccountOverdraftLimitParams struct {
+	ID             int64 `json:"id"`
+	OverdraftLimit int64 `json:"overdraft_limit"`
+}
+
+func (q *Queries) UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, updateAccountOverdraftLimit, arg.ID, arg.OverdraftLimit)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.OverdraftLimit,
+		&i.EntryChainHead,
 	)
 	return i, err
 }
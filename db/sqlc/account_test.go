@@ -14,14 +14,20 @@ import (
 // This function doesn't start with "Test" so it won’t be run automatically by `go test`.
 // Instead, it supports test functions by generating reliable test data for them.
 func createRandomAccount(t *testing.T) Account {
+	return createRandomAccountWithCurrency(t, util.RandomCurrency())
+}
+
+// createRandomAccountWithCurrency is createRandomAccount for tests that need to pick the currency,
+// a transfer between two random accounts would otherwise need an exchange rate
+func createRandomAccountWithCurrency(t *testing.T, currency string) Account {
 	user := CreateRandomUser(t)
 
 	// Prepare input parameters for account creation with random data
 	//* user.Username otherwise it would case a refernce key err
 	arg := CreateAccountParams{
-		Owner:    user.Username,         // Random string representing account owner name
-		Balance:  util.RandomMoney(),    // Random amount (float or int) for account balance
-		Currency: util.RandomCurrency(), // Randomly chosen currency (e.g., USD, EUR)
+		Owner:    user.Username,             // Random string representing account owner name
+		Balance:  util.RandomInt(100, 1000), //* at least the 10 x 10 the transfer tests move out of it
+		Currency: currency,                  // e.g. USD, EUR
 	}
 
 	//! Background: No deadline or cancellation needed for simple test
@@ -168,3 +174,49 @@ func TestListAccounts(t *testing.T) {
 //! 	 Run the actual test logic, passing in the tx-scoped Queries object
 //! 	testFunc(q)
 //! }
+
+// TestUpdateAccountFrozen checks an account can be frozen and unfrozen again
+func TestUpdateAccountFrozen(t *testing.T) {
+	account1 := createRandomAccount(t)
+	require.False(t, account1.IsFrozen)
+
+	account2, err := testQueries.UpdateAccountFrozen(context.Background(), UpdateAccountFrozenParams{
+		ID:       account1.ID,
+		IsFrozen: true,
+	})
+	require.NoError(t, err)
+	require.True(t, account2.IsFrozen)
+	require.Equal(t, account1.Balance, account2.Balance)
+
+	account3, err := testQueries.UpdateAccountFrozen(context.Background(), UpdateAccountFrozenParams{
+		ID:       account1.ID,
+		IsFrozen: false,
+	})
+	require.NoError(t, err)
+	require.False(t, account3.IsFrozen)
+}
+
+func TestUpdateAccountOverdraftLimit(t *testing.T) {
+	account1 := createRandomAccount(t)
+	require.Zero(t, account1.OverdraftLimit)
+
+	account2, err := testQueries.UpdateAccountOverdraftLimit(context.Background(), UpdateAccountOverdraftLimitParams{
+		ID:             account1.ID,
+		OverdraftLimit: 500,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(500), account2.OverdraftLimit)
+
+	//* the check constraints reject negative limits and balances below the limit
+	_, err = testQueries.UpdateAccountOverdraftLimit(context.Background(), UpdateAccountOverdraftLimitParams{
+		ID:             account1.ID,
+		OverdraftLimit: -1,
+	})
+	require.Error(t, err)
+
+	_, err = testQueries.UpdateAccount(context.Background(), UpdateAccountParams{
+		ID:      account1.ID,
+		Balance: -501,
+	})
+	require.Error(t, err)
+}
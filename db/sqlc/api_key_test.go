@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomApiKey(t *testing.T, owner User) ApiKey {
+	prefix, key, err := util.NewApiKey()
+	require.NoError(t, err)
+
+	arg := CreateApiKeyParams{
+		Owner:     owner.Username,
+		Name:      util.RandomOwner(),
+		Prefix:    prefix,
+		KeyHash:   util.HashSecretCode(key),
+		Scopes:    []string{util.ScopeAccountsRead, util.ScopeTransfersWrite},
+		ExpiredAt: time.Now().Add(time.Hour),
+	}
+
+	apiKey, err := testQueries.CreateApiKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, apiKey.ID)
+	require.Equal(t, arg.Owner, apiKey.Owner)
+	require.Equal(t, arg.Name, apiKey.Name)
+	require.Equal(t, arg.Prefix, apiKey.Prefix)
+	require.Equal(t, arg.KeyHash, apiKey.KeyHash)
+	require.Equal(t, arg.Scopes, apiKey.Scopes)
+	require.WithinDuration(t, arg.ExpiredAt, apiKey.ExpiredAt, time.Second)
+	require.NotZero(t, apiKey.CreatedAt)
+	return apiKey
+}
+
+func TestCreateApiKey(t *testing.T) {
+	createRandomApiKey(t, CreateRandomUser(t))
+}
+
+func TestGetApiKeyByPrefix(t *testing.T) {
+	apiKey1 := createRandomApiKey(t, CreateRandomUser(t))
+
+	apiKey2, err := testQueries.GetApiKeyByPrefix(context.Background(), apiKey1.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, apiKey1.ID, apiKey2.ID)
+	require.Equal(t, apiKey1.Scopes, apiKey2.Scopes)
+}
+
+func TestListApiKeys(t *testing.T) {
+	user := CreateRandomUser(t)
+	for i := 0; i < 3; i++ {
+		createRandomApiKey(t, user)
+	}
+	createRandomApiKey(t, CreateRandomUser(t))
+
+	apiKeys, err := testQueries.ListApiKeys(context.Background(), ListApiKeysParams{
+		Owner:  user.Username,
+		Limit:  5,
+		Offset: 0,
+	})
+	require.NoError(t, err)
+	require.Len(t, apiKeys, 3)
+	for _, apiKey := range apiKeys {
+		require.Equal(t, user.Username, apiKey.Owner)
+	}
+}
+
+func TestUpdateApiKey(t *testing.T) {
+	apiKey1 := createRandomApiKey(t, CreateRandomUser(t))
+
+	//* only the scopes are given, the name stays
+	apiKey2, err := testQueries.UpdateApiKey(context.Background(), UpdateApiKeyParams{
+		ID:     apiKey1.ID,
+		Scopes: []string{util.ScopeAccountsRead},
+	})
+	require.NoError(t, err)
+	require.Equal(t, apiKey1.Name, apiKey2.Name)
+	require.Equal(t, []string{util.ScopeAccountsRead}, apiKey2.Scopes)
+
+	newName := util.RandomOwner()
+	apiKey3, err := testQueries.UpdateApiKey(context.Background(), UpdateApiKeyParams{
+		ID:   apiKey1.ID,
+		Name: sql.NullString{String: newName, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newName, apiKey3.Name)
+	require.Equal(t, apiKey2.Scopes, apiKey3.Scopes)
+}
+
+func TestDeleteApiKey(t *testing.T) {
+	apiKey := createRandomApiKey(t, CreateRandomUser(t))
+
+	err := testQueries.DeleteApiKey(context.Background(), apiKey.ID)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetApiKey(context.Background(), apiKey.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -7,36 +7,52 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const createEntry = `-- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  transfer_id,
+  balance_after,
+  created_at
 ) VALUES (
-  $1, $2
-) RETURNING id, account_id, amount, created_at
+  $1, $2, $3, $4, clock_timestamp()
+) RETURNING id, account_id, amount, created_at, transfer_id, prev_hash, hash, balance_after
 `
 
 type CreateEntryParams struct {
-	AccountID int64 `json:"account_id"`
-	Amount    int64 `json:"amount"`
+	AccountID    int64         `json:"account_id"`
+	Amount       int64         `json:"amount"`
+	TransferID   sql.NullInt64 `json:"transfer_id"`
+	BalanceAfter int64         `json:"balance_after"`
 }
 
 func (q *Queries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error) {
-	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createEntry,
+		arg.AccountID,
+		arg.Amount,
+		arg.TransferID,
+		arg.BalanceAfter,
+	)
 	var i Entry
 	err := row.Scan(
 		&i.ID,
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.TransferID,
+		&i.PrevHash,
+		&i.Hash,
+		&i.BalanceAfter,
 	)
 	return i, err
 }
 
 const getEntry = `-- name: GetEntry :one
-SELECT id, account_id, amount, created_at FROM entries
+SELECT id, account_id, amount, created_at, transfer_id, prev_hash, hash, balance_after FROM entries
 WHERE id = $1 LIMIT 1
 `
 
@@ -48,12 +64,66 @@ func (q *Queries) GetEntry(ctx context.Context, id int64) (Entry, error) {
 		&i.AccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.TransferID,
+		&i.PrevHash,
+		&i.Hash,
+		&i.BalanceAfter,
+	)
+	return i, err
+}
+
+const getStatementSummary = `-- name: GetStatementSummary :one
+SELECT
+    COALESCE((
+        SELECT o.balance_after FROM entries o
+        WHERE o.account_id = $1 AND o.created_at < $2
+        ORDER BY o.created_at DESC, o.id DESC
+        LIMIT 1
+    ), 0)::bigint AS opening_balance,
+    COALESCE((
+        SELECT c.balance_after FROM entries c
+        WHERE c.account_id = $1 AND c.created_at < $3
+        ORDER BY c.created_at DESC, c.id DESC
+        LIMIT 1
+    ), 0)::bigint AS closing_balance,
+    COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0)::bigint AS total_credits,
+    COALESCE(-SUM(amount) FILTER (WHERE amount < 0), 0)::bigint AS total_debits,
+    COUNT(*)::int AS entry_count
+FROM entries
+WHERE account_id = $1
+    AND created_at >= $2
+    AND created_at < $3
+`
+
+type GetStatementSummaryParams struct {
+	AccountID int64     `json:"account_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+}
+
+type GetStatementSummaryRow struct {
+	OpeningBalance int64 `json:"opening_balance"`
+	ClosingBalance int64 `json:"closing_balance"`
+	TotalCredits   int64 `json:"total_credits"`
+	TotalDebits    int64 `json:"total_debits"`
+	EntryCount     int32 `json:"entry_count"`
+}
+
+func (q *Queries) GetStatementSummary(ctx context.Context, arg GetStatementSummaryParams) (GetStatementSummaryRow, error) {
+	row := q.db.QueryRowContext(ctx, getStatementSummary, arg.AccountID, arg.FromTime, arg.ToTime)
+	var i GetStatementSummaryRow
+	err := row.Scan(
+		&i.OpeningBalance,
+		&i.ClosingBalance,
+		&i.TotalCredits,
+		&i.TotalDebits,
+		&i.EntryCount,
 	)
 	return i, err
 }
 
 const listEntries = `-- name: ListEntries :many
-SELECT id, account_id, amount, created_at FROM entries
+SELECT id, account_id, amount, created_at, transfer_id, prev_hash, hash, balance_after FROM entries
 WHERE account_id = $1
 ORDER BY id
 LIMIT $2
@@ -80,6 +150,10 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 			&i.AccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.TransferID,
+			&i.PrevHash,
+			&i.Hash,
+			&i.BalanceAfter,
 		); err != nil {
 			return nil, err
 		}
@@ -93,3 +167,146 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 	}
 	return items, nil
 }
+
+const listEntryChain = `-- name: ListEntryChain :many
+SELECT id, account_id, amount, created_at, transfer_id, prev_hash, hash, balance_after FROM entries
+WHERE account_id = $1 AND id > $2
+ORDER BY id
+LIMIT $3
+`
+
+type ListEntryChainParams struct {
+	AccountID int64 `json:"account_id"`
+	AfterID   int64 `json:"after_id"`
+	PageSize  int32 `json:"page_size"`
+}
+
+func (q *Queries) ListEntryChain(ctx context.Context, arg ListEntryChainParams) ([]Entry, error) {
+	rows, err := q.db.QueryContext(ctx, listEntryChain, arg.AccountID, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.TransferID,
+			&i.PrevHash,
+			&i.Hash,
+			&i.BalanceAfter,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStatementEntries = `-- name: ListStatementEntries :many
+SELECT
+    e.id,
+    e.amount,
+    e.balance_after,
+    e.created_at,
+    e.transfer_id,
+    c.id AS counterparty_account_id,
+    c.owner AS counterparty_owner
+FROM entries e
+LEFT JOIN transfers t ON t.id = e.transfer_id
+LEFT JOIN accounts c ON c.id = CASE
+    WHEN t.from_account_id = e.account_id THEN t.to_account_id
+    ELSE t.from_account_id
+END
+WHERE e.account_id = $1
+    AND e.created_at >= $2
+    AND e.created_at < $3
+ORDER BY e.created_at, e.id
+`
+
+type ListStatementEntriesParams struct {
+	AccountID int64     `json:"account_id"`
+	FromTime  time.Time `json:"from_time"`
+	ToTime    time.Time `json:"to_time"`
+}
+
+type ListStatementEntriesRow struct {
+	ID                    int64          `json:"id"`
+	Amount                int64          `json:"amount"`
+	BalanceAfter          int64          `json:"balance_after"`
+	CreatedAt             time.Time      `json:"created_at"`
+	TransferID            sql.NullInt64  `json:"transfer_id"`
+	CounterpartyAccountID sql.NullInt64  `json:"counterparty_account_id"`
+	CounterpartyOwner     sql.NullString `json:"counterparty_owner"`
+}
+
+func (q *Queries) ListStatementEntries(ctx context.Context, arg ListStatementEntriesParams) ([]ListStatementEntriesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listStatementEntries, arg.AccountID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListStatementEntriesRow{}
+	for rows.Next() {
+		var i ListStatementEntriesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Amount,
+			&i.BalanceAfter,
+			&i.CreatedAt,
+			&i.TransferID,
+			&i.CounterpartyAccountID,
+			&i.CounterpartyOwner,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setEntryHash = `-- name: SetEntryHash :one
+UPDATE entries
+SET prev_hash = $2,
+    hash = $3
+WHERE id = $1
+RETURNING id, account_id, amount, created_at, transfer_id, prev_hash, hash, balance_after
+`
+
+type SetEntryHashParams struct {
+	ID       int64  `json:"id"`
+	PrevHash []byte `json:"prev_hash"`
+	Hash     []byte `json:"hash"`
+}
+
+func (q *Queries) SetEntryHash(ctx context.Context, arg SetEntryHashParams) (Entry, error) {
+	row := q.db.QueryRowContext(ctx, setEntryHash, arg.ID, arg.PrevHash, arg.Hash)
+	var i Entry
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.TransferID,
+		&i.PrevHash,
+		&i.Hash,
+		&i.BalanceAfter,
+	)
+	return i, err
+}
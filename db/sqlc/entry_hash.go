@@ -0,0 +1,153 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+)
+
+// ^ every entry carries a hash over its contents and the hash of the account's previous entry,
+// ^ so editing, deleting or reordering an entry breaks every link after it.
+// ^ The account row keeps the hash of the newest entry, so dropping entries off the end shows too.
+// ^ The chain of an account only grows while its row is locked, TransferTx and friends hold that lock.
+
+// entryChainPageSize is how many entries VerifyEntryChain reads at a time
+const entryChainPageSize = 1000
+
+// ^ why a link is broken, as reported in EntryChainBreak
+const (
+	ChainMissingHash  = "missing_hash"
+	ChainPrevMismatch = "prev_hash_mismatch"
+	ChainHashMismatch = "hash_mismatch"
+	ChainHeadMismatch = "head_mismatch"
+)
+
+// entryHash is sha256(prev_hash || id || account_id || amount || transfer_id || created_at in unix micros || balance_after),
+// the numbers as 8 byte big endian and a null transfer as 0. Migration 000029 backfills with the same bytes.
+func entryHash(prevHash []byte, entry Entry) []byte {
+	var buf bytes.Buffer
+	buf.Write(prevHash)
+	for _, n := range []int64{entry.ID, entry.AccountID, entry.Amount, entry.TransferID.Int64, entry.CreatedAt.UnixMicro(), entry.BalanceAfter} {
+		binary.Write(&buf, binary.BigEndian, n)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return sum[:]
+}
+
+// createChainedEntry posts amount as a new entry of the locked account and links it to the account's chain,
+// the caller must hold the account's row lock so nothing is appended or posted in between
+func createChainedEntry(ctx context.Context, q *Queries, account Account, amount int64, transferID sql.NullInt64) (Entry, error) {
+	prevHash := account.EntryChainHead
+
+	//* balance_after is hashed, so it is worked out from the locked balance before the insert
+	entry, err := q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    account.ID,
+		Amount:       amount,
+		TransferID:   transferID,
+		BalanceAfter: account.Balance + amount,
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+
+	//* id and created_at come from the insert, so the hash is set right after it in the same transaction
+	entry, err = q.SetEntryHash(ctx, SetEntryHashParams{
+		ID:       entry.ID,
+		PrevHash: prevHash,
+		Hash:     entryHash(prevHash, entry),
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return entry, q.SetAccountEntryChainHead(ctx, SetAccountEntryChainHeadParams{
+		ID:             account.ID,
+		EntryChainHead: entry.Hash,
+	})
+}
+
+// EntryChainResult is the outcome of walking one account's chain.
+type EntryChainResult struct {
+	AccountID      int64 `json:"account_id"`
+	EntriesChecked int64 `json:"entries_checked"`
+	Valid          bool  `json:"valid"`
+	//* the first broken link, nil when Valid
+	Break *EntryChainBreak `json:"break,omitempty"`
+}
+
+// EntryChainBreak pinpoints the first entry that doesn't follow from the ones before it.
+// For a head mismatch EntryID is the last entry left, 0 if there is none.
+type EntryChainBreak struct {
+	EntryID int64  `json:"entry_id"`
+	Reason  string `json:"reason"`
+	//* for a prev_hash mismatch the hash of the previous entry, for a hash mismatch the recomputed hash,
+	//* for a head mismatch the head kept on the account
+	Expected []byte `json:"expected"`
+	Actual   []byte `json:"actual"`
+}
+
+// VerifyEntryChain walks the entries of an account from the first and stops at the first broken link.
+// The chain has to reach the head kept on the account, it returns sql.ErrNoRows for an unknown account.
+func VerifyEntryChain(ctx context.Context, q Querier, accountID int64) (EntryChainResult, error) {
+	result := EntryChainResult{AccountID: accountID, Valid: true}
+
+	//* read before the walk, entries appended meanwhile come after it and are checked as links
+	account, err := q.GetAccount(ctx, accountID)
+	if err != nil {
+		return result, err
+	}
+	headSeen := account.EntryChainHead == nil
+
+	var prevHash []byte
+	var afterID int64
+	for {
+		entries, err := q.ListEntryChain(ctx, ListEntryChainParams{
+			AccountID: accountID,
+			AfterID:   afterID,
+			PageSize:  entryChainPageSize,
+		})
+		if err != nil {
+			return result, err
+		}
+
+		for _, entry := range entries {
+			result.EntriesChecked++
+			if chainBreak := checkEntryLink(prevHash, entry); chainBreak != nil {
+				result.Valid = false
+				result.Break = chainBreak
+				return result, nil
+			}
+			headSeen = headSeen || bytes.Equal(entry.Hash, account.EntryChainHead)
+			prevHash = entry.Hash
+			afterID = entry.ID
+		}
+
+		if len(entries) < entryChainPageSize {
+			break
+		}
+	}
+
+	//* catches the newest entries being deleted, which leaves a shorter chain that is valid on its own
+	if !headSeen {
+		result.Valid = false
+		result.Break = &EntryChainBreak{EntryID: afterID, Reason: ChainHeadMismatch, Expected: account.EntryChainHead, Actual: prevHash}
+	}
+	return result, nil
+}
+
+func checkEntryLink(prevHash []byte, entry Entry) *EntryChainBreak {
+	if entry.Hash == nil {
+		return &EntryChainBreak{EntryID: entry.ID, Reason: ChainMissingHash}
+	}
+	//* catches a deleted or reordered entry before it
+	if !bytes.Equal(entry.PrevHash, prevHash) {
+		return &EntryChainBreak{EntryID: entry.ID, Reason: ChainPrevMismatch, Expected: prevHash, Actual: entry.PrevHash}
+	}
+	//* catches an edit of the entry itself
+	if expected := entryHash(prevHash, entry); !bytes.Equal(entry.Hash, expected) {
+		return &EntryChainBreak{EntryID: entry.ID, Reason: ChainHashMismatch, Expected: expected, Actual: entry.Hash}
+	}
+	return nil
+}
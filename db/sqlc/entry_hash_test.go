@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferTxChainsEntries(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	var results []TransferTxResult
+	for i := 0; i < 3; i++ {
+		result, err := testStore.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+		})
+		require.NoError(t, err)
+		results = append(results, result)
+	}
+
+	//* the first entry of an account starts its chain, the next ones link to it
+	require.Nil(t, results[0].FromEntry.PrevHash)
+	require.Equal(t, results[0].FromEntry.Hash, results[1].FromEntry.PrevHash)
+	require.Equal(t, results[1].ToEntry.Hash, results[2].ToEntry.PrevHash)
+	require.Len(t, results[2].ToEntry.Hash, 32)
+
+	for _, account := range []Account{account1, account2} {
+		verification, err := VerifyEntryChain(context.Background(), testQueries, account.ID)
+		require.NoError(t, err)
+		require.True(t, verification.Valid)
+		require.Equal(t, int64(3), verification.EntriesChecked)
+		require.Nil(t, verification.Break)
+	}
+}
+
+func TestVerifyEntryChainEdited(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	var entries []Entry
+	for i := 0; i < 3; i++ {
+		result, err := testStore.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+		})
+		require.NoError(t, err)
+		entries = append(entries, result.ToEntry)
+	}
+
+	_, err := testDB.Exec(`UPDATE entries SET amount = amount + 1 WHERE id = $1`, entries[1].ID)
+	require.NoError(t, err)
+
+	verification, err := VerifyEntryChain(context.Background(), testQueries, account2.ID)
+	require.NoError(t, err)
+	require.False(t, verification.Valid)
+	require.Equal(t, int64(2), verification.EntriesChecked)
+	require.Equal(t, entries[1].ID, verification.Break.EntryID)
+	require.Equal(t, ChainHashMismatch, verification.Break.Reason)
+}
+
+func TestVerifyEntryChainDeleted(t *testing.T) {
+	account := createRandomAccountWithCurrency(t, util.USD)
+
+	var entries []Entry
+	for i := 0; i < 3; i++ {
+		result, err := testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account.ID, Amount: 10})
+		require.NoError(t, err)
+		entries = append(entries, result.Entry)
+	}
+
+	_, err := testDB.Exec(`DELETE FROM entries WHERE id = $1`, entries[1].ID)
+	require.NoError(t, err)
+
+	//* the entry after the gap no longer follows from the one before it
+	verification, err := VerifyEntryChain(context.Background(), testQueries, account.ID)
+	require.NoError(t, err)
+	require.False(t, verification.Valid)
+	require.Equal(t, entries[2].ID, verification.Break.EntryID)
+	require.Equal(t, ChainPrevMismatch, verification.Break.Reason)
+	require.Equal(t, entries[0].Hash, verification.Break.Expected)
+}
+
+func TestVerifyEntryChainEditedBalanceAfter(t *testing.T) {
+	account := createRandomAccountWithCurrency(t, util.USD)
+
+	result, err := testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account.ID, Amount: 10})
+	require.NoError(t, err)
+	require.Equal(t, account.Balance+10, result.Entry.BalanceAfter)
+
+	_, err = testDB.Exec(`UPDATE entries SET balance_after = balance_after + 1000 WHERE id = $1`, result.Entry.ID)
+	require.NoError(t, err)
+
+	verification, err := VerifyEntryChain(context.Background(), testQueries, account.ID)
+	require.NoError(t, err)
+	require.False(t, verification.Valid)
+	require.Equal(t, result.Entry.ID, verification.Break.EntryID)
+	require.Equal(t, ChainHashMismatch, verification.Break.Reason)
+}
+
+func TestVerifyEntryChainTruncated(t *testing.T) {
+	account := createRandomAccountWithCurrency(t, util.USD)
+
+	var entries []Entry
+	for i := 0; i < 3; i++ {
+		result, err := testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account.ID, Amount: 10})
+		require.NoError(t, err)
+		entries = append(entries, result.Entry)
+	}
+
+	account, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, entries[2].Hash, account.EntryChainHead)
+
+	_, err = testDB.Exec(`DELETE FROM entries WHERE id = $1`, entries[2].ID)
+	require.NoError(t, err)
+
+	//* what is left is a valid chain, only the head on the account tells it was longer
+	verification, err := VerifyEntryChain(context.Background(), testQueries, account.ID)
+	require.NoError(t, err)
+	require.False(t, verification.Valid)
+	require.Equal(t, int64(2), verification.EntriesChecked)
+	require.Equal(t, entries[1].ID, verification.Break.EntryID)
+	require.Equal(t, ChainHeadMismatch, verification.Break.Reason)
+	require.Equal(t, entries[2].Hash, verification.Break.Expected)
+	require.Equal(t, entries[1].Hash, verification.Break.Actual)
+}
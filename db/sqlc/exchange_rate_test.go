@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertExchangeRate(t *testing.T) {
+	arg := UpsertExchangeRateParams{
+		FromCurrency: util.EUR,
+		ToCurrency:   util.CAD,
+		Rate:         1.47,
+		SpreadBps:    25,
+	}
+
+	rate1, err := testQueries.UpsertExchangeRate(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Rate, rate1.Rate)
+	require.Equal(t, arg.SpreadBps, rate1.SpreadBps)
+
+	//* the same pair again replaces the rate instead of adding a row
+	arg.Rate = 1.5
+	rate2, err := testQueries.UpsertExchangeRate(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, 1.5, rate2.Rate)
+	require.False(t, rate2.UpdatedAt.Before(rate1.UpdatedAt))
+
+	rate3, err := testQueries.GetExchangeRate(context.Background(), GetExchangeRateParams{
+		FromCurrency: util.EUR,
+		ToCurrency:   util.CAD,
+	})
+	require.NoError(t, err)
+	require.Equal(t, rate2, rate3)
+
+	rates, err := testQueries.ListExchangeRates(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, rates, rate3)
+
+	arg.Rate = 0
+	_, err = testQueries.UpsertExchangeRate(context.Background(), arg)
+	require.Error(t, err)
+}
+
+func TestUpsertExchangeRatesTxRollsBack(t *testing.T) {
+	rate1, err := testQueries.UpsertExchangeRate(context.Background(), UpsertExchangeRateParams{
+		FromCurrency: util.CAD,
+		ToCurrency:   util.EUR,
+		Rate:         0.68,
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.UpsertExchangeRatesTx(context.Background(), []UpsertExchangeRateParams{
+		{FromCurrency: util.CAD, ToCurrency: util.EUR, Rate: 0.7},
+		{FromCurrency: util.EUR, ToCurrency: util.CAD, Rate: 0},
+	})
+	require.Error(t, err)
+
+	//* the good rate was written before the bad one and rolled back with it
+	rate2, err := testQueries.GetExchangeRate(context.Background(), GetExchangeRateParams{
+		FromCurrency: util.CAD,
+		ToCurrency:   util.EUR,
+	})
+	require.NoError(t, err)
+	require.Equal(t, rate1, rate2)
+}
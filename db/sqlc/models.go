@@ -5,7 +5,11 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Account struct {
@@ -14,6 +18,36 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
+	IsFrozen  bool      `json:"is_frozen"`
+	// how far below zero the balance may go
+	OverdraftLimit int64 `json:"overdraft_limit"`
+	// hash of the newest entry of the account, moved in the transaction that appends it
+	EntryChainHead []byte `json:"entry_chain_head"`
+}
+
+type ApiKey struct {
+	ID    int64  `json:"id"`
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	// public part of the key, used to look it up
+	Prefix string `json:"prefix"`
+	// sha256 of the full key
+	KeyHash   string    `json:"key_hash"`
+	Scopes    []string  `json:"scopes"`
+	ExpiredAt time.Time `json:"expired_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Client struct {
+	ID    string `json:"id"`
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	// sha256 of the client secret, empty for public clients
+	SecretHash   string   `json:"secret_hash"`
+	RedirectUris []string `json:"redirect_uris"`
+	// the most a client may ask for
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Entry struct {
@@ -22,6 +56,119 @@ type Entry struct {
 	// can be +/-
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// the transfer that posted it, null for deposits and withdrawals
+	TransferID sql.NullInt64 `json:"transfer_id"`
+	// hash of the previous entry of the same account, null for its first
+	PrevHash []byte `json:"prev_hash"`
+	// sha256 over prev_hash and the entry, see entryHash; set in the transaction that inserts the entry
+	Hash []byte `json:"hash"`
+	// balance of the account once the entry was posted, part of hash
+	BalanceAfter int64 `json:"balance_after"`
+}
+
+type ExchangeRate struct {
+	FromCurrency string `json:"from_currency"`
+	ToCurrency   string `json:"to_currency"`
+	// units of to_currency for one unit of from_currency
+	Rate float64 `json:"rate"`
+	// basis points kept by the bank on conversion
+	SpreadBps int32     `json:"spread_bps"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type IdempotencyKey struct {
+	Owner string `json:"owner"`
+	// value of the Idempotency-Key header, unique per user
+	IdempotencyKey string `json:"idempotency_key"`
+	// sha256 of the request body the key was first used with
+	RequestHash    string    `json:"request_hash"`
+	ResponseStatus int32     `json:"response_status"`
+	ResponseBody   []byte    `json:"response_body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type OauthCode struct {
+	CodeHash    string   `json:"code_hash"`
+	ClientID    string   `json:"client_id"`
+	Username    string   `json:"username"`
+	RedirectUri string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+	// PKCE S256 challenge
+	CodeChallenge string    `json:"code_challenge"`
+	IsUsed        bool      `json:"is_used"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiredAt     time.Time `json:"expired_at"`
+}
+
+type PasswordReset struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// sha256 of the emailed token
+	TokenHash string    `json:"token_hash"`
+	IsUsed    bool      `json:"is_used"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+type ReconciliationRun struct {
+	ID               int64 `json:"id"`
+	AccountsChecked  int64 `json:"accounts_checked"`
+	TransfersChecked int64 `json:"transfers_checked"`
+	// accounts whose balance is not the sum of their entries
+	DriftedAccounts int32 `json:"drifted_accounts"`
+	// transfers without any entries
+	OrphanedTransfers int32 `json:"orphaned_transfers"`
+	// transfers with entries that are not exactly one matching debit and one matching credit
+	UnbalancedTransfers int32           `json:"unbalanced_transfers"`
+	Findings            json.RawMessage `json:"findings"`
+	StartedAt           time.Time       `json:"started_at"`
+	FinishedAt          time.Time       `json:"finished_at"`
+}
+
+type RecoveryCode struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// sha256 of the recovery code
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type RevokedToken struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `json:"id"`
+	Owner         string `json:"owner"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	Amount        int64  `json:"amount"`
+	// five field cron expression or @every <duration>
+	Schedule string       `json:"schedule"`
+	StartsAt time.Time    `json:"starts_at"`
+	EndsAt   sql.NullTime `json:"ends_at"`
+	// null once the schedule has ended
+	NextRunAt sql.NullTime `json:"next_run_at"`
+	LastRunAt sql.NullTime `json:"last_run_at"`
+	LastError string       `json:"last_error"`
+	// lease of the scheduler replica running it
+	LockedUntil sql.NullTime `json:"locked_until"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	RefreshToken string    `json:"refresh_token"`
+	UserAgent    string    `json:"user_agent"`
+	ClientIp     string    `json:"client_ip"`
+	IsBlocked    bool      `json:"is_blocked"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type Transfer struct {
@@ -31,6 +178,35 @@ type Transfer struct {
 	// must be +ve
 	Amount    int64     `json:"amount"`
 	CreatedAt time.Time `json:"created_at"`
+	// amount credited, in the currency of the destination account
+	ToAmount int64 `json:"to_amount"`
+	// destination units per source unit, 1 for same-currency transfers
+	ExchangeRate float64 `json:"exchange_rate"`
+	// spread taken on the conversion, in basis points
+	SpreadBps int32 `json:"spread_bps"`
+	// completed, partially_reversed or reversed
+	Status string `json:"status"`
+	// part of amount sent back so far, in the source currency
+	ReversedAmount int64 `json:"reversed_amount"`
+	// the transfer this one compensates
+	ReversalOf sql.NullInt64 `json:"reversal_of"`
+}
+
+type TransferLimit struct {
+	ID int64 `json:"id"`
+	// set for a user limit, a null field falls back to the configured default
+	Owner sql.NullString `json:"owner"`
+	// set for an account limit, a null field means no limit
+	AccountID sql.NullInt64 `json:"account_id"`
+	// largest single transfer
+	MaxAmount sql.NullInt64 `json:"max_amount"`
+	// total sent in any 24 hours
+	DailyAmount sql.NullInt64 `json:"daily_amount"`
+	// transfers sent in any 24 hours
+	DailyCount sql.NullInt32 `json:"daily_count"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	// set with owner, a user limit covers their accounts in this currency
+	Currency sql.NullString `json:"currency"`
 }
 
 type User struct {
@@ -40,4 +216,32 @@ type User struct {
 	Email             string    `json:"email"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
+	// depositor or banker
+	Role string `json:"role"`
+	// AES-GCM encrypted TOTP secret
+	TotpSecret  []byte `json:"totp_secret"`
+	TotpEnabled bool   `json:"totp_enabled"`
+	// failed logins since the last successful one
+	FailedLoginAttempts int32 `json:"failed_login_attempts"`
+	// logins are refused until then (backoff or lockout)
+	LockedUntil     time.Time `json:"locked_until"`
+	IsEmailVerified bool      `json:"is_email_verified"`
+	// set when the user is deactivated, their accounts become read-only
+	DeactivatedAt sql.NullTime `json:"deactivated_at"`
+}
+
+type VerifyEmail struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	SecretCode string    `json:"secret_code"`
+	IsUsed     bool      `json:"is_used"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiredAt  time.Time `json:"expired_at"`
+}
+
+type UserTokenRevocation struct {
+	Username string `json:"username"`
+	// tokens issued before this are rejected
+	RevokedBefore time.Time `json:"revoked_before"`
 }
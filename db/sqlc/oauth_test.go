@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomClient(t *testing.T, owner User) Client {
+	clientID, err := util.NewClientID()
+	require.NoError(t, err)
+
+	arg := CreateClientParams{
+		ID:           clientID,
+		Owner:        owner.Username,
+		Name:         util.RandomOwner(),
+		SecretHash:   util.HashSecretCode(util.RandomString(32)),
+		RedirectUris: []string{"https://example.com/callback"},
+		Scopes:       []string{util.ScopeAccountsRead},
+	}
+
+	client, err := testQueries.CreateClient(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, client.ID)
+	require.Equal(t, arg.Owner, client.Owner)
+	require.Equal(t, arg.SecretHash, client.SecretHash)
+	require.Equal(t, arg.RedirectUris, client.RedirectUris)
+	require.Equal(t, arg.Scopes, client.Scopes)
+	require.NotZero(t, client.CreatedAt)
+	return client
+}
+
+func TestGetClient(t *testing.T) {
+	client1 := createRandomClient(t, CreateRandomUser(t))
+
+	client2, err := testQueries.GetClient(context.Background(), client1.ID)
+	require.NoError(t, err)
+	require.Equal(t, client1.Name, client2.Name)
+	require.Equal(t, client1.RedirectUris, client2.RedirectUris)
+}
+
+func TestUseOauthCode(t *testing.T) {
+	user := CreateRandomUser(t)
+	client := createRandomClient(t, user)
+
+	arg := CreateOauthCodeParams{
+		CodeHash:      util.HashSecretCode(util.RandomString(32)),
+		ClientID:      client.ID,
+		Username:      user.Username,
+		RedirectUri:   client.RedirectUris[0],
+		Scopes:        client.Scopes,
+		CodeChallenge: util.PKCEChallenge(util.RandomString(64)),
+		ExpiredAt:     time.Now().Add(time.Minute),
+	}
+	_, err := testQueries.CreateOauthCode(context.Background(), arg)
+	require.NoError(t, err)
+
+	code, err := testQueries.UseOauthCode(context.Background(), arg.CodeHash)
+	require.NoError(t, err)
+	require.True(t, code.IsUsed)
+	require.Equal(t, arg.CodeChallenge, code.CodeChallenge)
+	require.Equal(t, arg.Scopes, code.Scopes)
+
+	//* single use
+	_, err = testQueries.UseOauthCode(context.Background(), arg.CodeHash)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestExpiredOauthCode(t *testing.T) {
+	user := CreateRandomUser(t)
+	client := createRandomClient(t, user)
+
+	arg := CreateOauthCodeParams{
+		CodeHash:      util.HashSecretCode(util.RandomString(32)),
+		ClientID:      client.ID,
+		Username:      user.Username,
+		RedirectUri:   client.RedirectUris[0],
+		Scopes:        client.Scopes,
+		CodeChallenge: util.PKCEChallenge(util.RandomString(64)),
+		ExpiredAt:     time.Now().Add(-time.Minute),
+	}
+	_, err := testQueries.CreateOauthCode(context.Background(), arg)
+	require.NoError(t, err)
+
+	_, err = testQueries.UseOauthCode(context.Background(), arg.CodeHash)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestDeleteClientCascadesCodes(t *testing.T) {
+	user := CreateRandomUser(t)
+	client := createRandomClient(t, user)
+
+	codeHash := util.HashSecretCode(util.RandomString(32))
+	_, err := testQueries.CreateOauthCode(context.Background(), CreateOauthCodeParams{
+		CodeHash:      codeHash,
+		ClientID:      client.ID,
+		Username:      user.Username,
+		RedirectUri:   client.RedirectUris[0],
+		Scopes:        client.Scopes,
+		CodeChallenge: util.PKCEChallenge(util.RandomString(64)),
+		ExpiredAt:     time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, testQueries.DeleteClient(context.Background(), client.ID))
+
+	_, err = testQueries.GetClient(context.Background(), client.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = testQueries.UseOauthCode(context.Background(), codeHash)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
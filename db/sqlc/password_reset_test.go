@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPasswordTx(t *testing.T) {
+	user := CreateRandomUser(t)
+	session := createRandomSession(t, user)
+
+	tokenHash := util.HashSecretCode(util.RandomString(32))
+	reset, err := testQueries.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+		Username:  user.Username,
+		TokenHash: tokenHash,
+		ExpiredAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.False(t, reset.IsUsed)
+
+	hashedPassword, err := util.HashedPassword(util.RandomString(10))
+	require.NoError(t, err)
+
+	arg := ResetPasswordTxParams{
+		TokenHash:         tokenHash,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	}
+
+	updated, err := testStore.ResetPasswordTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, hashedPassword, updated.HashedPassword)
+	require.WithinDuration(t, arg.PasswordChangedAt, updated.PasswordChangedAt, time.Second)
+
+	blocked, err := testQueries.GetSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, blocked.IsBlocked)
+
+	//* single use
+	_, err = testStore.ResetPasswordTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestExpiredPasswordReset(t *testing.T) {
+	user := CreateRandomUser(t)
+
+	tokenHash := util.HashSecretCode(util.RandomString(32))
+	_, err := testQueries.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+		Username:  user.Username,
+		TokenHash: tokenHash,
+		ExpiredAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.UsePasswordReset(context.Background(), tokenHash)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createEmptyAccount(t *testing.T) Account {
+	user := CreateRandomUser(t)
+	account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Currency: util.USD,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestListAccountDrift(t *testing.T) {
+	//* createRandomAccount sets its balance without entries, so start both from zero
+	account1 := createEmptyAccount(t)
+	account2 := createEmptyAccount(t)
+
+	//* an UpdateAccount without entries is exactly the drift the check is for
+	account1, err := testQueries.UpdateAccount(context.Background(), UpdateAccountParams{
+		ID:      account1.ID,
+		Balance: 7,
+	})
+	require.NoError(t, err)
+
+	//* deposits go through entries and don't drift
+	_, err = testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account2.ID, Amount: 10})
+	require.NoError(t, err)
+
+	drift, err := testQueries.ListAccountDrift(context.Background())
+	require.NoError(t, err)
+
+	drifted := map[int64]ListAccountDriftRow{}
+	for _, row := range drift {
+		drifted[row.ID] = row
+	}
+	require.Contains(t, drifted, account1.ID)
+	require.Equal(t, int64(7), drifted[account1.ID].Balance)
+	require.Zero(t, drifted[account1.ID].EntriesTotal)
+	require.NotContains(t, drifted, account2.ID)
+}
+
+func TestListUnbalancedTransfers(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	result, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, result.Transfer.ID, result.FromEntry.TransferID.Int64)
+	require.Equal(t, result.Transfer.ID, result.ToEntry.TransferID.Int64)
+
+	//* a transfer row written without its entries
+	orphan := createRandomTransfer(t, account1, account2)
+
+	//* self transfers are rejected now, older ones still balance
+	self, err := testQueries.CreateTransfer(context.Background(), CreateTransferParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account1.ID,
+		Amount:        5,
+		ToAmount:      5,
+		ExchangeRate:  1,
+	})
+	require.NoError(t, err)
+	for _, amount := range []int64{-5, 5} {
+		_, err = testQueries.CreateEntry(context.Background(), CreateEntryParams{
+			AccountID:  account1.ID,
+			Amount:     amount,
+			TransferID: sql.NullInt64{Int64: self.ID, Valid: true},
+		})
+		require.NoError(t, err)
+	}
+
+	unbalanced, err := testQueries.ListUnbalancedTransfers(context.Background())
+	require.NoError(t, err)
+
+	found := map[int64]ListUnbalancedTransfersRow{}
+	for _, row := range unbalanced {
+		found[row.ID] = row
+	}
+	require.NotContains(t, found, result.Transfer.ID)
+	require.NotContains(t, found, self.ID)
+	require.Contains(t, found, orphan.ID)
+	require.Zero(t, found[orphan.ID].EntryCount)
+}
+
+func TestCreateReconciliationRun(t *testing.T) {
+	arg := CreateReconciliationRunParams{
+		AccountsChecked:  2,
+		TransfersChecked: 1,
+		DriftedAccounts:  1,
+		Findings:         []byte(`{"drifted_accounts":[{"account_id":1}]}`),
+		StartedAt:        time.Now(),
+	}
+
+	run1, err := testQueries.CreateReconciliationRun(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.DriftedAccounts, run1.DriftedAccounts)
+	require.JSONEq(t, string(arg.Findings), string(run1.Findings))
+
+	run2, err := testQueries.GetReconciliationRun(context.Background(), run1.ID)
+	require.NoError(t, err)
+	require.Equal(t, run1.ID, run2.ID)
+	require.WithinDuration(t, run1.FinishedAt, run2.FinishedAt, time.Second)
+
+	runs, err := testQueries.ListReconciliationRuns(context.Background(), ListReconciliationRunsParams{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.GreaterOrEqual(t, runs[0].ID, run1.ID)
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetTOTPTx(t *testing.T) {
+	user := CreateRandomUser(t)
+	require.False(t, user.TotpEnabled)
+
+	codes, err := util.GenerateRecoveryCodes(3)
+	require.NoError(t, err)
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = util.HashRecoveryCode(code)
+	}
+
+	secret := []byte(util.RandomString(32))
+	updated, err := testStore.SetTOTPTx(context.Background(), SetTOTPTxParams{
+		Username:           user.Username,
+		TotpSecret:         secret,
+		TotpEnabled:        true,
+		RecoveryCodeHashes: hashes,
+	})
+	require.NoError(t, err)
+	require.True(t, updated.TotpEnabled)
+	require.Equal(t, secret, updated.TotpSecret)
+
+	arg := UseRecoveryCodeParams{Username: user.Username, CodeHash: hashes[0]}
+
+	used, err := testQueries.UseRecoveryCode(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, used.UsedAt.Valid)
+
+	//* a recovery code only works once
+	_, err = testQueries.UseRecoveryCode(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	//* disabling removes the remaining codes too
+	disabled, err := testStore.SetTOTPTx(context.Background(), SetTOTPTxParams{Username: user.Username})
+	require.NoError(t, err)
+	require.False(t, disabled.TotpEnabled)
+	require.Empty(t, disabled.TotpSecret)
+
+	_, err = testQueries.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{Username: user.Username, CodeHash: hashes[1]})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
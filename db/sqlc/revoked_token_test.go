@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeToken(t *testing.T) {
+	user := CreateRandomUser(t)
+
+	arg := RevokeTokenParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), arg.ID)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	err = testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	//* revoking twice is a no-op
+	err = testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg.ID)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	user := CreateRandomUser(t)
+	cutoff := time.Now()
+
+	err := testQueries.RevokeUserTokens(context.Background(), RevokeUserTokensParams{
+		Username:      user.Username,
+		RevokedBefore: cutoff,
+	})
+	require.NoError(t, err)
+
+	revoked, err := testQueries.IsUserTokenRevoked(context.Background(), IsUserTokenRevokedParams{
+		Username: user.Username,
+		IssuedAt: cutoff.Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = testQueries.IsUserTokenRevoked(context.Background(), IsUserTokenRevokedParams{
+		Username: user.Username,
+		IssuedAt: cutoff.Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
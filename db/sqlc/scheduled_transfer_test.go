@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomScheduledTransfer(t *testing.T, nextRunAt time.Time) ScheduledTransfer {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	arg := CreateScheduledTransferParams{
+		Owner:         account1.Owner,
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+		Schedule:      "@every 24h",
+		StartsAt:      nextRunAt,
+		NextRunAt:     sql.NullTime{Time: nextRunAt, Valid: true},
+	}
+
+	scheduled, err := testQueries.CreateScheduledTransfer(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, scheduled.ID)
+	require.Equal(t, arg.Owner, scheduled.Owner)
+	require.Equal(t, arg.Schedule, scheduled.Schedule)
+	require.WithinDuration(t, nextRunAt, scheduled.NextRunAt.Time, time.Second)
+	require.False(t, scheduled.EndsAt.Valid)
+	require.Empty(t, scheduled.LastError)
+	return scheduled
+}
+
+func TestGetAndDeleteScheduledTransfer(t *testing.T) {
+	scheduled1 := createRandomScheduledTransfer(t, time.Now().Add(time.Hour))
+
+	scheduled2, err := testQueries.GetScheduledTransfer(context.Background(), scheduled1.ID)
+	require.NoError(t, err)
+	require.Equal(t, scheduled1.ID, scheduled2.ID)
+
+	list, err := testQueries.ListScheduledTransfers(context.Background(), ListScheduledTransfersParams{
+		Owner: scheduled1.Owner,
+		Limit: 5,
+	})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	require.NoError(t, testQueries.DeleteScheduledTransfer(context.Background(), scheduled1.ID))
+
+	_, err = testQueries.GetScheduledTransfer(context.Background(), scheduled1.ID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func claimedIDs(t *testing.T, lease time.Duration) map[int64]bool {
+	claimed, err := testQueries.ClaimDueScheduledTransfers(context.Background(), ClaimDueScheduledTransfersParams{
+		LockedUntil: sql.NullTime{Time: time.Now().Add(lease), Valid: true},
+		BatchSize:   1000,
+	})
+	require.NoError(t, err)
+
+	ids := make(map[int64]bool)
+	for _, scheduled := range claimed {
+		ids[scheduled.ID] = true
+	}
+	return ids
+}
+
+func TestClaimDueScheduledTransfers(t *testing.T) {
+	due := createRandomScheduledTransfer(t, time.Now().Add(-time.Minute))
+	later := createRandomScheduledTransfer(t, time.Now().Add(time.Hour))
+
+	ids := claimedIDs(t, time.Minute)
+	require.True(t, ids[due.ID])
+	require.False(t, ids[later.ID])
+
+	//* still leased, nobody else gets it
+	require.False(t, claimedIDs(t, time.Minute)[due.ID])
+
+	next := sql.NullTime{Time: time.Now().Add(24 * time.Hour), Valid: true}
+	finished, err := testQueries.FinishScheduledTransferRun(context.Background(), FinishScheduledTransferRunParams{
+		ID:        due.ID,
+		NextRunAt: next,
+		LastError: "insufficient funds",
+	})
+	require.NoError(t, err)
+	require.False(t, finished.LockedUntil.Valid)
+	require.True(t, finished.LastRunAt.Valid)
+	require.Equal(t, "insufficient funds", finished.LastError)
+
+	//* not due any more
+	require.False(t, claimedIDs(t, time.Minute)[due.ID])
+}
+
+func TestClaimExpiredLease(t *testing.T) {
+	due := createRandomScheduledTransfer(t, time.Now().Add(-time.Minute))
+
+	//* a replica claims it and dies, once the lease runs out another one takes over
+	require.True(t, claimedIDs(t, -time.Second)[due.ID])
+	require.True(t, claimedIDs(t, time.Minute)[due.ID])
+}
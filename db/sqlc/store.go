@@ -3,7 +3,12 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+
+	"github.com/lib/pq"
 )
 
 // Store provides all functions to execute db queries and transactions.
@@ -18,6 +23,16 @@ type Store interface {
 	//* now adding all fucnion of queries struct is difficukt so sqlc has emit_interface
 	Querier
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error)
+	DepositTx(ctx context.Context, arg DepositTxParams) (ClearingTxResult, error)
+	WithdrawTx(ctx context.Context, arg WithdrawTxParams) (ClearingTxResult, error)
+	StatementTx(ctx context.Context, arg StatementTxParams) (StatementTxResult, error)
+	SetTOTPTx(ctx context.Context, arg SetTOTPTxParams) (User, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error)
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error)
+	UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error)
+	UpsertExchangeRatesTx(ctx context.Context, arg []UpsertExchangeRateParams) ([]ExchangeRate, error)
 }
 
 // NewStore creates a new Store.
@@ -56,6 +71,18 @@ type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
 	Amount        int64 `json:"amount"`
+	//* optional, stores the response under the client's Idempotency-Key in the same transaction
+	Idempotency *TransferIdempotency `json:"-"`
+	//* the sender's limits unless a banker set their own, see checkTransferLimits
+	DefaultLimits TransferLimitsByCurrency `json:"-"`
+}
+
+// TransferIdempotency identifies a retried transfer request and the response it got.
+type TransferIdempotency struct {
+	Owner          string
+	Key            string
+	RequestHash    string
+	ResponseStatus int32
 }
 
 // TransferTxResult is the result of the transfer transaction.
@@ -67,82 +94,208 @@ type TransferTxResult struct {
 	ToEntry     Entry    `json:"to_entry"`
 }
 
+// ErrInsufficientFunds is returned by TransferTx when the debit would take the
+// source account below zero, or below its overdraft limit if it has one.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ^ the CHECK constraint that backs up the balance check in TransferTx
+const accountsBalanceCheck = "accounts_balance_check"
+
+// ErrNoExchangeRate is returned by TransferTx for accounts in different
+// currencies when there is no rate between them.
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// ErrAmountTooSmall is returned by TransferTx when the converted amount rounds down to nothing.
+var ErrAmountTooSmall = errors.New("amount too small to convert")
+
+// ErrSameAccount is returned by TransferTx when both sides are the same account.
+var ErrSameAccount = errors.New("cannot transfer to the same account")
+
+// ErrIdempotencyKeyExists is returned by TransferTx when another request with the
+// same idempotency key committed first, the transfer has been rolled back.
+var ErrIdempotencyKeyExists = errors.New("idempotency key already used")
+
+// ^ primary key of idempotency_keys, a concurrent retry waits on it and then fails
+const idempotencyKeysPkey = "idempotency_keys_pkey"
+
 // TransferTx performs a money transfer from one account to another.
 // It creates a transfer record, two ledger entries, and updates both accounts’ balances.
+// Between currencies the source is debited Amount and the destination credited the
+// converted amount, the rate and spread used are kept on the transfer.
 // We also log the per-transaction name from the context for debugging.
 func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
 	var result TransferTxResult
 
+	//* nothing would move, and the two entries could not be told apart
+	if arg.FromAccountID == arg.ToAccountID {
+		return result, fmt.Errorf("%w: account [%d]", ErrSameAccount, arg.FromAccountID)
+	}
+
 	err := store.execTx(ctx, func(q *Queries) error {
 		var err error
 		// txName := ctx.Value(txKey)
 
-		// 1) create transfer record
+		// 0) lock both accounts (smaller id first, as in step 4) and check the balance and limits,
+		//    nobody can debit the source account between the check and the update
+
+		fromAccount, toAccount, err := lockAccounts(ctx, q, arg.FromAccountID, arg.ToAccountID)
+		if err != nil {
+			return err
+		}
+		if fromAccount.Owner == ClearingAccountOwner || toAccount.Owner == ClearingAccountOwner {
+			return fmt.Errorf("%w: transfer [%d] -> [%d]", ErrClearingAccount, fromAccount.ID, toAccount.ID)
+		}
+		if fromAccount.Balance+fromAccount.OverdraftLimit < arg.Amount {
+			return fmt.Errorf("%w: account [%d] has %d available, transfer needs %d",
+				ErrInsufficientFunds, fromAccount.ID, fromAccount.Balance+fromAccount.OverdraftLimit, arg.Amount)
+		}
+		if err := checkTransferLimits(ctx, q, fromAccount, arg); err != nil {
+			return err
+		}
+
+		// 1) create transfer record, converting the amount if the currencies differ
+
+		transfer := CreateTransferParams{
+			FromAccountID: arg.FromAccountID,
+			ToAccountID:   arg.ToAccountID,
+			Amount:        arg.Amount,
+			ToAmount:      arg.Amount,
+			ExchangeRate:  1,
+		}
+		if fromAccount.Currency != toAccount.Currency {
+			rate, err := q.GetExchangeRate(ctx, GetExchangeRateParams{
+				FromCurrency: fromAccount.Currency,
+				ToCurrency:   toAccount.Currency,
+			})
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("%w: %s to %s", ErrNoExchangeRate, fromAccount.Currency, toAccount.Currency)
+				}
+				return err
+			}
+
+			transfer.ExchangeRate = rate.Rate
+			transfer.SpreadBps = rate.SpreadBps
+			transfer.ToAmount = convertAmount(arg.Amount, rate.Rate, rate.SpreadBps)
+			if transfer.ToAmount < 1 {
+				return fmt.Errorf("%w: %d %s", ErrAmountTooSmall, arg.Amount, fromAccount.Currency)
+			}
+		}
 
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams(arg))
+		result.Transfer, err = q.CreateTransfer(ctx, transfer)
 		if err != nil {
 			return err
 		}
 
 		// 2) create debit entry
 
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
-		})
+		transferID := sql.NullInt64{Int64: result.Transfer.ID, Valid: true}
+		result.FromEntry, err = createChainedEntry(ctx, q, fromAccount, -arg.Amount, transferID)
 		if err != nil {
 			return err
 		}
 
 		// 3) create credit entry
 
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
-		})
+		result.ToEntry, err = createChainedEntry(ctx, q, toAccount, transfer.ToAmount, transferID)
 		if err != nil {
 			return err
 		}
 
-		// 4) update balances (with SELECT ... FOR UPDATE inside GetAccountForUpdate)
+		// 4) update balances (the rows are still locked from GetAccountForUpdate), landing on each entry's balance_after
 
 		//^ IN OUR CASE TO HAVE A CONSISTENT DB AND DEADLOCK AVOIDANCE WE USE QUERYSEQUENCING := USE A ORDER OF TRANSC HERE WE USE SMALLER ID FIRST
 
 		if arg.FromAccountID < arg.ToAccountID {
 
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
+			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, result.FromEntry, result.ToEntry)
 
 		} else {
 			//^ to account should be updated!!
 
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, arg.ToAccountID, +arg.Amount, arg.FromAccountID, -arg.Amount)
+			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, result.ToEntry, result.FromEntry)
 
 		}
 
-		return nil
+		//* only reachable if a balance was changed without taking the row lock
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == accountsBalanceCheck {
+			return fmt.Errorf("%w: %v", ErrInsufficientFunds, pqErr)
+		}
+		if err != nil || arg.Idempotency == nil {
+			return err
+		}
+
+		// 5) remember the response, the transfer only commits together with its key
+
+		return recordIdempotency(ctx, q, arg.Idempotency, result)
 	})
 
 	return result, err
 }
 
+func recordIdempotency(ctx context.Context, q *Queries, idem *TransferIdempotency, result TransferTxResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.CreateIdempotencyKey(ctx, CreateIdempotencyKeyParams{
+		Owner:          idem.Owner,
+		IdempotencyKey: idem.Key,
+		RequestHash:    idem.RequestHash,
+		ResponseStatus: idem.ResponseStatus,
+		ResponseBody:   body,
+	})
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == idempotencyKeysPkey {
+		return fmt.Errorf("%w: %q", ErrIdempotencyKeyExists, idem.Key)
+	}
+	return err
+}
+
+// convertAmount applies the rate minus the spread, rounding down so the bank never pays out a fraction
+func convertAmount(amount int64, rate float64, spreadBps int32) int64 {
+	converted := float64(amount) * rate * float64(10000-spreadBps) / 10000
+	//* 0.29 * 100 is 28.999999999999996 in float64, don't let that cost a whole unit
+	return int64(math.Floor(converted + 1e-9))
+}
+
+// lockAccounts takes the row locks of both accounts in id order and returns them as source and destination
+func lockAccounts(ctx context.Context, q *Queries, fromAccountID int64, toAccountID int64) (Account, Account, error) {
+	firstID, secondID := fromAccountID, toAccountID
+	if secondID < firstID {
+		firstID, secondID = secondID, firstID
+	}
+
+	first, err := q.GetAccountForUpdate(ctx, firstID)
+	if err != nil {
+		return Account{}, Account{}, err
+	}
+	second, err := q.GetAccountForUpdate(ctx, secondID)
+	if err != nil {
+		return Account{}, Account{}, err
+	}
+
+	if first.ID == fromAccountID {
+		return first, second, nil
+	}
+	return second, first, nil
+}
+
 //* why the traditional locking failed
 
 //^ 1-> You never lock the rows you read, so between your SELECT and your UPDATE, another transfer can slip in and stomp on your balance calculation
 //^ 2-> you must explicitly acquire locks when you need serialized access to specific rows.
 
+// addMoney posts two entries to their accounts' balances, entry1's account first.
+// The entries already carry the balance they leave behind, a different result means the row lock wasn't held.
 func addMoney(
 	ctx context.Context,
 	q *Queries,
-	accountID1 int64,
-	amount1 int64,
-	accountID2 int64,
-	amount2 int64,
+	entry1 Entry,
+	entry2 Entry,
 
 ) (account1 Account, account2 Account, err error) {
-	account1, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-		ID:     accountID1,
-		Amount: amount1,
-	})
+	account1, err = addEntryToBalance(ctx, q, entry1)
 
 	if err != nil {
 		return
@@ -150,17 +303,22 @@ func addMoney(
 		// return account1, account2, err
 	}
 
-	account2, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-		ID:     accountID2,
-		Amount: amount2,
-	})
-
-	if err != nil {
-		return
-		//^ here since we are using named return params we can just return empty!!
-		// return account1, account2, err
-	}
+	account2, err = addEntryToBalance(ctx, q, entry2)
 
 	return
 
 }
+
+func addEntryToBalance(ctx context.Context, q *Queries, entry Entry) (Account, error) {
+	account, err := q.AddAccountBalance(ctx, AddAccountBalanceParams{
+		ID:     entry.AccountID,
+		Amount: entry.Amount,
+	})
+	if err != nil {
+		return Account{}, err
+	}
+	if account.Balance != entry.BalanceAfter {
+		return Account{}, fmt.Errorf("account [%d] is at %d, entry [%d] expected %d", account.ID, account.Balance, entry.ID, entry.BalanceAfter)
+	}
+	return account, nil
+}
@@ -2,16 +2,18 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 
+	"github.com/itsadijmbt/simple_bank/db/util"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTransferTxDeadlock(t *testing.T) {
 	store := NewStore(testDB)
 	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
 
 	n, amount := 10, int64(10)
 	errs := make(chan error, n)
@@ -55,7 +57,7 @@ func TestTransferTx(t *testing.T) {
 	// set up in your TestMain (in another _test.go)
 	store := NewStore(testDB)
 	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
 	fmt.Println(">> before:", account1.Balance, account2.Balance)
 
 	n := 10
@@ -94,6 +96,8 @@ func TestTransferTx(t *testing.T) {
 		require.Equal(t, account1.ID, tr.FromAccountID)
 		require.Equal(t, account2.ID, tr.ToAccountID)
 		require.Equal(t, amount, tr.Amount)
+		require.Equal(t, amount, tr.ToAmount)
+		require.Equal(t, float64(1), tr.ExchangeRate)
 		require.NotZero(t, tr.ID)
 
 		// entries
@@ -137,3 +141,233 @@ func TestTransferTx(t *testing.T) {
 	require.Equal(t, account1.Balance-int64(n)*amount, updated1.Balance)
 	require.Equal(t, account2.Balance+int64(n)*amount, updated2.Balance)
 }
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	_, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        account1.Balance + 1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	//* nothing of the transfer is left behind
+	updated1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updated1.Balance)
+
+	transfers, err := testQueries.ListTransfers(context.Background(), ListTransfersParams{
+		FromAccountID: account1.ID,
+		Limit:         5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, transfers)
+}
+
+func TestTransferTxSameAccount(t *testing.T) {
+	account1 := createRandomAccount(t)
+
+	_, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account1.ID,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, ErrSameAccount)
+
+	transfers, err := testQueries.ListTransfers(context.Background(), ListTransfersParams{
+		FromAccountID: account1.ID,
+		Limit:         5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, transfers)
+}
+
+func TestTransferTxOverdraft(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	account1, err := testQueries.UpdateAccountOverdraftLimit(context.Background(), UpdateAccountOverdraftLimitParams{
+		ID:             account1.ID,
+		OverdraftLimit: 100,
+	})
+	require.NoError(t, err)
+
+	result, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        account1.Balance + 100,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(-100), result.FromAccount.Balance)
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestTransferTxConcurrentOverspend(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	//* every transfer alone would fit, together they overdraw the account
+	n := 5
+	amount := account1.Balance/int64(n-1) + 1
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := testStore.TransferTx(context.Background(), TransferTxParams{
+				FromAccountID: account1.ID,
+				ToAccountID:   account2.ID,
+				Amount:        amount,
+			})
+			errs <- err
+		}()
+	}
+
+	failed := 0
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+			failed++
+		}
+	}
+	require.NotZero(t, failed)
+
+	updated1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, updated1.Balance, int64(0))
+	require.Equal(t, account1.Balance-int64(n-failed)*amount, updated1.Balance)
+}
+
+func TestTransferTxIdempotencyKey(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	arg := TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+		Idempotency: &TransferIdempotency{
+			Owner:          account1.Owner,
+			Key:            util.RandomString(32),
+			RequestHash:    util.RandomString(64),
+			ResponseStatus: 200,
+		},
+	}
+
+	result, err := testStore.TransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	stored, err := testQueries.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		Owner:          arg.Idempotency.Owner,
+		IdempotencyKey: arg.Idempotency.Key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, arg.Idempotency.RequestHash, stored.RequestHash)
+	require.Equal(t, int32(200), stored.ResponseStatus)
+
+	var replayed TransferTxResult
+	require.NoError(t, json.Unmarshal(stored.ResponseBody, &replayed))
+	require.Equal(t, result.Transfer.ID, replayed.Transfer.ID)
+
+	//* the second transfer under the same key is rolled back
+	_, err = testStore.TransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrIdempotencyKeyExists)
+
+	updated1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-arg.Amount, updated1.Balance)
+}
+
+func TestTransferTxCrossCurrency(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account2 := createRandomAccountWithCurrency(t, util.EUR)
+
+	_, err := testQueries.UpsertExchangeRate(context.Background(), UpsertExchangeRateParams{
+		FromCurrency: util.USD,
+		ToCurrency:   util.EUR,
+		Rate:         0.5,
+		SpreadBps:    100,
+	})
+	require.NoError(t, err)
+
+	result, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	//* 100 USD at 0.5 is 50 EUR, less 1% spread and rounded down
+	require.Equal(t, int64(100), result.Transfer.Amount)
+	require.Equal(t, int64(49), result.Transfer.ToAmount)
+	require.Equal(t, 0.5, result.Transfer.ExchangeRate)
+	require.Equal(t, int32(100), result.Transfer.SpreadBps)
+
+	require.Equal(t, int64(-100), result.FromEntry.Amount)
+	require.Equal(t, int64(49), result.ToEntry.Amount)
+	require.Equal(t, account1.Balance-100, result.FromAccount.Balance)
+	require.Equal(t, account2.Balance+49, result.ToAccount.Balance)
+}
+
+func TestTransferTxNoExchangeRate(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.CAD)
+	account2 := createRandomAccountWithCurrency(t, util.INR)
+
+	//* another test may have stored this pair, rates can't be deleted so pick one nothing writes
+	_, err := testQueries.GetExchangeRate(context.Background(), GetExchangeRateParams{
+		FromCurrency: util.CAD,
+		ToCurrency:   util.INR,
+	})
+	if err == nil {
+		t.Skip("CAD/INR rate exists in this database")
+	}
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.ErrorIs(t, err, ErrNoExchangeRate)
+}
+
+func TestConvertAmount(t *testing.T) {
+	require.Equal(t, int64(29), convertAmount(100, 0.29, 0))
+	require.Equal(t, int64(49), convertAmount(100, 0.5, 100))
+	require.Equal(t, int64(8320), convertAmount(100, 83.2, 0))
+	require.Equal(t, int64(0), convertAmount(1, 0.012, 0))
+}
+
+func TestTransferTxBalanceAfter(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	for i := int64(1); i <= 3; i++ {
+		result, err := testStore.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+		})
+		require.NoError(t, err)
+
+		//* each entry snapshots its account right after it was posted
+		require.Equal(t, account1.Balance-10*i, result.FromEntry.BalanceAfter)
+		require.Equal(t, account2.Balance+10*i, result.ToEntry.BalanceAfter)
+		require.Equal(t, result.FromAccount.Balance, result.FromEntry.BalanceAfter)
+		require.Equal(t, result.ToAccount.Balance, result.ToEntry.BalanceAfter)
+	}
+
+	entries, err := testQueries.ListEntries(context.Background(), ListEntriesParams{
+		AccountID: account2.ID,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, account2.Balance+30, entries[2].BalanceAfter)
+}
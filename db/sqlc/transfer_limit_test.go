@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func requireLimitExceeded(t *testing.T, err error, scope string, limit string) *LimitExceededError {
+	var limitErr *LimitExceededError
+	require.True(t, errors.As(err, &limitErr), "want a LimitExceededError, got %v", err)
+	require.Equal(t, scope, limitErr.Scope)
+	require.Equal(t, limit, limitErr.Limit)
+	return limitErr
+}
+
+func TestTransferTxDefaultMaxAmount(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	_, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        20,
+		DefaultLimits: TransferLimitsByCurrency{account1.Currency: {MaxAmount: 10}},
+	})
+	limitErr := requireLimitExceeded(t, err, LimitScopeUser, LimitMaxAmount)
+	require.Equal(t, int64(10), limitErr.Max)
+
+	//* nothing moved
+	account, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, account.Balance)
+}
+
+func TestTransferTxUserDailyAmount(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	//* a second account of the same user in another currency has its own limit
+	account3, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    account1.Owner,
+		Balance:  100,
+		Currency: util.EUR,
+	})
+	require.NoError(t, err)
+	account4 := createRandomAccountWithCurrency(t, util.EUR)
+
+	for _, currency := range []string{util.USD, util.EUR} {
+		_, err = testQueries.UpsertUserTransferLimit(context.Background(), UpsertUserTransferLimitParams{
+			Owner:       sql.NullString{String: account1.Owner, Valid: true},
+			Currency:    sql.NullString{String: currency, Valid: true},
+			DailyAmount: sql.NullInt64{Int64: 50, Valid: true},
+		})
+		require.NoError(t, err)
+	}
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        30,
+	})
+	require.NoError(t, err)
+
+	//* the dollars sent don't count against the euro limit
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account3.ID,
+		ToAccountID:   account4.ID,
+		Amount:        30,
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account3.ID,
+		ToAccountID:   account4.ID,
+		Amount:        30,
+	})
+	limitErr := requireLimitExceeded(t, err, LimitScopeUser, LimitDailyAmount)
+	require.Equal(t, int64(30), limitErr.Used)
+	require.Equal(t, int64(30), limitErr.Requested)
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account3.ID,
+		ToAccountID:   account4.ID,
+		Amount:        20,
+	})
+	require.NoError(t, err)
+}
+
+func TestTransferTxUserLimitOverridesDefault(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	_, err := testQueries.UpsertUserTransferLimit(context.Background(), UpsertUserTransferLimitParams{
+		Owner:     sql.NullString{String: account1.Owner, Valid: true},
+		Currency:  sql.NullString{String: account1.Currency, Valid: true},
+		MaxAmount: sql.NullInt64{Int64: 50, Valid: true},
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        40,
+		DefaultLimits: TransferLimitsByCurrency{account1.Currency: {MaxAmount: 10, DailyCount: 1}},
+	})
+	require.NoError(t, err)
+
+	//* the default daily count the row left unset still applies
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        1,
+		DefaultLimits: TransferLimitsByCurrency{account1.Currency: {MaxAmount: 10, DailyCount: 1}},
+	})
+	requireLimitExceeded(t, err, LimitScopeUser, LimitDailyCount)
+}
+
+func TestTransferTxAccountDailyCount(t *testing.T) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	_, err := testQueries.UpsertAccountTransferLimit(context.Background(), UpsertAccountTransferLimitParams{
+		AccountID:  sql.NullInt64{Int64: account1.ID, Valid: true},
+		DailyCount: sql.NullInt32{Int32: 2, Valid: true},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        1,
+		})
+		require.NoError(t, err)
+	}
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        1,
+	})
+	limitErr := requireLimitExceeded(t, err, LimitScopeAccount, LimitDailyCount)
+	require.Equal(t, int64(2), limitErr.Used)
+}
+
+func TestUpsertUserTransferLimit(t *testing.T) {
+	user := CreateRandomUser(t)
+	owner := sql.NullString{String: user.Username, Valid: true}
+	usd := sql.NullString{String: util.USD, Valid: true}
+
+	limit1, err := testQueries.UpsertUserTransferLimit(context.Background(), UpsertUserTransferLimitParams{
+		Owner:     owner,
+		Currency:  usd,
+		MaxAmount: sql.NullInt64{Int64: 100, Valid: true},
+	})
+	require.NoError(t, err)
+
+	limit2, err := testQueries.UpsertUserTransferLimit(context.Background(), UpsertUserTransferLimitParams{
+		Owner:       owner,
+		Currency:    usd,
+		DailyAmount: sql.NullInt64{Int64: 1000, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, limit1.ID, limit2.ID)
+	require.False(t, limit2.MaxAmount.Valid)
+	require.Equal(t, int64(1000), limit2.DailyAmount.Int64)
+
+	limit3, err := testQueries.GetUserTransferLimit(context.Background(), GetUserTransferLimitParams{
+		Owner:    owner,
+		Currency: usd,
+	})
+	require.NoError(t, err)
+	require.Equal(t, limit2, limit3)
+
+	//* each currency gets its own row
+	limit4, err := testQueries.UpsertUserTransferLimit(context.Background(), UpsertUserTransferLimitParams{
+		Owner:     owner,
+		Currency:  sql.NullString{String: util.EUR, Valid: true},
+		MaxAmount: sql.NullInt64{Int64: 100, Valid: true},
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, limit1.ID, limit4.ID)
+}
+
+func TestTransferLimitsFromConfig(t *testing.T) {
+	limits, err := TransferLimitsFromConfig(util.Config{})
+	require.NoError(t, err)
+	require.Nil(t, limits)
+
+	limits, err = TransferLimitsFromConfig(util.Config{
+		TransferMaxAmounts:   "USD:1000, INR:80000",
+		TransferDailyAmounts: "USD:5000",
+		TransferDailyCount:   10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferLimitsByCurrency{
+		util.USD: {MaxAmount: 1000, DailyAmount: 5000, DailyCount: 10},
+		util.EUR: {DailyCount: 10},
+		util.CAD: {DailyCount: 10},
+		util.INR: {MaxAmount: 80000, DailyCount: 10},
+	}, limits)
+
+	for _, amounts := range []string{"USD", "USD:0", "USD:ten", "XYZ:10", "USD:10,USD:20"} {
+		_, err = TransferLimitsFromConfig(util.Config{TransferMaxAmounts: amounts})
+		require.Error(t, err, amounts)
+	}
+}
+
+func TestTransferTxDefaultLimitsPerCurrency(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	//* the euro default doesn't hold dollars back
+	_, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        20,
+		DefaultLimits: TransferLimitsByCurrency{util.EUR: {MaxAmount: 10}},
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        20,
+		DefaultLimits: TransferLimitsByCurrency{util.EUR: {MaxAmount: 10}, util.USD: {MaxAmount: 15}},
+	})
+	limitErr := requireLimitExceeded(t, err, LimitScopeUser, LimitMaxAmount)
+	require.Equal(t, int64(15), limitErr.Max)
+}
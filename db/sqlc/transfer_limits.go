@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+)
+
+// ^ names of the limits, as they show up in a LimitExceededError
+const (
+	LimitScopeUser    = "user"
+	LimitScopeAccount = "account"
+
+	LimitMaxAmount   = "max_amount"
+	LimitDailyAmount = "daily_amount"
+	LimitDailyCount  = "daily_count"
+)
+
+// TransferLimits caps what can be sent, a zero field is no limit.
+// The daily ones are a rolling 24 hours, reversals don't count.
+// A user's limits apply to each currency on its own, in that currency.
+type TransferLimits struct {
+	MaxAmount   int64 `json:"max_amount"`
+	DailyAmount int64 `json:"daily_amount"`
+	DailyCount  int32 `json:"daily_count"`
+}
+
+// TransferLimitsByCurrency holds the default limits of each currency, an amount
+// means something else in every currency so they can't share one number.
+type TransferLimitsByCurrency map[string]TransferLimits
+
+// TransferLimitsFromConfig reads the defaults: TRANSFER_MAX_AMOUNTS and TRANSFER_DAILY_AMOUNTS
+// list "CUR:amount,CUR:amount", TRANSFER_DAILY_COUNT holds for every currency.
+// It is nil when none of them is set.
+func TransferLimitsFromConfig(config util.Config) (TransferLimitsByCurrency, error) {
+	maxAmounts, err := parseCurrencyAmounts("TRANSFER_MAX_AMOUNTS", config.TransferMaxAmounts)
+	if err != nil {
+		return nil, err
+	}
+	dailyAmounts, err := parseCurrencyAmounts("TRANSFER_DAILY_AMOUNTS", config.TransferDailyAmounts)
+	if err != nil {
+		return nil, err
+	}
+
+	var limits TransferLimitsByCurrency
+	for _, currency := range util.Currencies {
+		currencyLimits := TransferLimits{
+			MaxAmount:   maxAmounts[currency],
+			DailyAmount: dailyAmounts[currency],
+			DailyCount:  config.TransferDailyCount,
+		}
+		if currencyLimits == (TransferLimits{}) {
+			continue
+		}
+		if limits == nil {
+			limits = TransferLimitsByCurrency{}
+		}
+		limits[currency] = currencyLimits
+	}
+	return limits, nil
+}
+
+func parseCurrencyAmounts(name string, value string) (map[string]int64, error) {
+	amounts := map[string]int64{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		currency, amount, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !util.IsSupportedCurrency(currency) {
+			return nil, fmt.Errorf("invalid %s entry %q, expected CUR:amount", name, entry)
+		}
+		if _, exists := amounts[currency]; exists {
+			return nil, fmt.Errorf("duplicate currency %s in %s", currency, name)
+		}
+
+		n, err := strconv.ParseInt(amount, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid %s amount %q for %s", name, amount, currency)
+		}
+		amounts[currency] = n
+	}
+	return amounts, nil
+}
+
+// LimitExceededError is returned by TransferTx when a transfer would go over a limit.
+type LimitExceededError struct {
+	Scope string `json:"scope"`
+	Limit string `json:"limit"`
+	Max   int64  `json:"max"`
+	//* already sent in the last 24 hours, 0 for max_amount
+	Used      int64 `json:"used"`
+	Requested int64 `json:"requested"`
+}
+
+func (err *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s %s limit of %d exceeded: %d used, %d requested", err.Scope, err.Limit, err.Max, err.Used, err.Requested)
+}
+
+// checkTransferLimits runs inside TransferTx with both accounts locked, the sender's
+// user row is locked too so transfers from their other accounts wait for this one
+func checkTransferLimits(ctx context.Context, q *Queries, fromAccount Account, arg TransferTxParams) error {
+
+	//* account limits only exist if a banker set them
+	accountLimit, err := q.GetAccountTransferLimit(ctx, sql.NullInt64{Int64: fromAccount.ID, Valid: true})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return err
+	default:
+		limits := TransferLimits{}.override(accountLimit)
+		totals, err := q.GetAccountTransferTotals(ctx, fromAccount.ID)
+		if err != nil {
+			return err
+		}
+		if err := limits.check(LimitScopeAccount, totals.Total, totals.Count, arg.Amount); err != nil {
+			return err
+		}
+	}
+
+	//* amounts in different currencies can't be added up, user limits are per currency
+	userLimit, err := q.GetUserTransferLimit(ctx, GetUserTransferLimitParams{
+		Owner:    sql.NullString{String: fromAccount.Owner, Valid: true},
+		Currency: sql.NullString{String: fromAccount.Currency, Valid: true},
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	limits := arg.DefaultLimits[fromAccount.Currency].override(userLimit)
+	if limits == (TransferLimits{}) {
+		return nil
+	}
+
+	if _, err := q.GetUserForUpdate(ctx, fromAccount.Owner); err != nil {
+		return err
+	}
+	totals, err := q.GetUserTransferTotals(ctx, GetUserTransferTotalsParams{
+		Owner:    fromAccount.Owner,
+		Currency: fromAccount.Currency,
+	})
+	if err != nil {
+		return err
+	}
+	return limits.check(LimitScopeUser, totals.Total, totals.Count, arg.Amount)
+}
+
+// override replaces the limits the row sets, the rest are kept
+func (limits TransferLimits) override(row TransferLimit) TransferLimits {
+	if row.MaxAmount.Valid {
+		limits.MaxAmount = row.MaxAmount.Int64
+	}
+	if row.DailyAmount.Valid {
+		limits.DailyAmount = row.DailyAmount.Int64
+	}
+	if row.DailyCount.Valid {
+		limits.DailyCount = row.DailyCount.Int32
+	}
+	return limits
+}
+
+func (limits TransferLimits) check(scope string, total int64, count int32, amount int64) error {
+	if limits.MaxAmount > 0 && amount > limits.MaxAmount {
+		return &LimitExceededError{Scope: scope, Limit: LimitMaxAmount, Max: limits.MaxAmount, Requested: amount}
+	}
+	if limits.DailyAmount > 0 && total+amount > limits.DailyAmount {
+		return &LimitExceededError{Scope: scope, Limit: LimitDailyAmount, Max: limits.DailyAmount, Used: total, Requested: amount}
+	}
+	if limits.DailyCount > 0 && count+1 > limits.DailyCount {
+		return &LimitExceededError{Scope: scope, Limit: LimitDailyCount, Max: int64(limits.DailyCount), Used: int64(count), Requested: 1}
+	}
+	return nil
+}
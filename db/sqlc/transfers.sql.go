@@ -7,26 +7,43 @@ package db
 
 import (
 	"context"
+	"database/sql"
 )
 
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers (
   from_account_id,
   to_account_id,
-  amount
+  amount,
+  to_amount,
+  exchange_rate,
+  spread_bps,
+  reversal_of
 ) VALUES (
-  $1, $2, $3
-) RETURNING id, from_account_id, to_account_id, amount, created_at
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, spread_bps, status, reversed_amount, reversal_of
 `
 
 type CreateTransferParams struct {
-	FromAccountID int64 `json:"from_account_id"`
-	ToAccountID   int64 `json:"to_account_id"`
-	Amount        int64 `json:"amount"`
+	FromAccountID int64         `json:"from_account_id"`
+	ToAccountID   int64         `json:"to_account_id"`
+	Amount        int64         `json:"amount"`
+	ToAmount      int64         `json:"to_amount"`
+	ExchangeRate  float64       `json:"exchange_rate"`
+	SpreadBps     int32         `json:"spread_bps"`
+	ReversalOf    sql.NullInt64 `json:"reversal_of"`
 }
 
 func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error) {
-	row := q.db.QueryRowContext(ctx, createTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ToAmount,
+		arg.ExchangeRate,
+		arg.SpreadBps,
+		arg.ReversalOf,
+	)
 	var i Transfer
 	err := row.Scan(
 		&i.ID,
@@ -34,12 +51,38 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.SpreadBps,
+		&i.Status,
+		&i.ReversedAmount,
+		&i.ReversalOf,
 	)
 	return i, err
 }
 
+const getAccountTransferTotals = `-- name: GetAccountTransferTotals :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS total, COUNT(*)::int AS count
+FROM transfers
+WHERE from_account_id = $1
+  AND reversal_of IS NULL
+  AND created_at > now() - interval '24 hours'
+`
+
+type GetAccountTransferTotalsRow struct {
+	Total int64 `json:"total"`
+	Count int32 `json:"count"`
+}
+
+func (q *Queries) GetAccountTransferTotals(ctx context.Context, fromAccountID int64) (GetAccountTransferTotalsRow, error) {
+	row := q.db.QueryRowContext(ctx, getAccountTransferTotals, fromAccountID)
+	var i GetAccountTransferTotalsRow
+	err := row.Scan(&i.Total, &i.Count)
+	return i, err
+}
+
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, spread_bps, status, reversed_amount, reversal_of FROM transfers
 WHERE id = $1 LIMIT 1
 `
 
@@ -52,12 +95,70 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.SpreadBps,
+		&i.Status,
+		&i.ReversedAmount,
+		&i.ReversalOf,
+	)
+	return i, err
+}
+
+const getTransferForUpdate = `-- name: GetTransferForUpdate :one
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, spread_bps, status, reversed_amount, reversal_of FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, getTransferForUpdate, id)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.SpreadBps,
+		&i.Status,
+		&i.ReversedAmount,
+		&i.ReversalOf,
 	)
 	return i, err
 }
 
+const getUserTransferTotals = `-- name: GetUserTransferTotals :one
+SELECT COALESCE(SUM(t.amount), 0)::bigint AS total, COUNT(*)::int AS count
+FROM transfers t
+JOIN accounts a ON a.id = t.from_account_id
+WHERE a.owner = $1
+  AND a.currency = $2
+  AND t.reversal_of IS NULL
+  AND t.created_at > now() - interval '24 hours'
+`
+
+type GetUserTransferTotalsParams struct {
+	Owner    string `json:"owner"`
+	Currency string `json:"currency"`
+}
+
+type GetUserTransferTotalsRow struct {
+	Total int64 `json:"total"`
+	Count int32 `json:"count"`
+}
+
+func (q *Queries) GetUserTransferTotals(ctx context.Context, arg GetUserTransferTotalsParams) (GetUserTransferTotalsRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserTransferTotals, arg.Owner, arg.Currency)
+	var i GetUserTransferTotalsRow
+	err := row.Scan(&i.Total, &i.Count)
+	return i, err
+}
+
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, spread_bps, status, reversed_amount, reversal_of
 FROM transfers
 WHERE from_account_id = $1
    OR to_account_id   = $1
@@ -87,6 +188,12 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ToAmount,
+			&i.ExchangeRate,
+			&i.SpreadBps,
+			&i.Status,
+			&i.ReversedAmount,
+			&i.ReversalOf,
 		); err != nil {
 			return nil, err
 		}
@@ -100,3 +207,36 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 	}
 	return items, nil
 }
+
+const updateTransferReversal = `-- name: UpdateTransferReversal :one
+UPDATE transfers
+SET reversed_amount = $2,
+    status = $3
+WHERE id = $1
+RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, exchange_rate, spread_bps, status, reversed_amount, reversal_of
+`
+
+type UpdateTransferReversalParams struct {
+	ID             int64  `json:"id"`
+	ReversedAmount int64  `json:"reversed_amount"`
+	Status         string `json:"status"`
+}
+
+func (q *Queries) UpdateTransferReversal(ctx context.Context, arg UpdateTransferReversalParams) (Transfer, error) {
+	row := q.db.QueryRowContext(ctx, updateTransferReversal, arg.ID, arg.ReversedAmount, arg.Status)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.ExchangeRate,
+		&i.SpreadBps,
+		&i.Status,
+		&i.ReversedAmount,
+		&i.ReversalOf,
+	)
+	return i, err
+}
@@ -0,0 +1,48 @@
+package db
+
+import "context"
+
+// CreateUserTxParams contains the new user and the code for their verification email.
+type CreateUserTxParams struct {
+	CreateUserParams
+	SecretCode string `json:"secret_code"`
+	//* runs inside the transaction, an error rolls the new user back
+	AfterCreate func(user User, verifyEmail VerifyEmail) error `json:"-"`
+}
+
+// CreateUserTxResult is the result of the create user transaction.
+type CreateUserTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// CreateUserTx creates the user together with a pending email verification,
+// so a user never exists without a way to verify their address.
+func (store *SQLStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error) {
+	var result CreateUserTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:   result.User.Username,
+			Email:      result.User.Email,
+			SecretCode: arg.SecretCode,
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.AfterCreate != nil {
+			return arg.AfterCreate(result.User, result.VerifyEmail)
+		}
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ClearingAccountOwner owns the per-currency clearing accounts, money from outside
+// the bank is credited to a customer and debited to clearing so the entries always balance
+const ClearingAccountOwner = "system"
+
+// ErrClearingAccount is returned when a customer operation names a clearing account.
+var ErrClearingAccount = errors.New("clearing accounts only move money through deposits and withdrawals")
+
+// ErrNoClearingAccount is returned by DepositTx and WithdrawTx for a currency without a clearing account.
+var ErrNoClearingAccount = errors.New("no clearing account")
+
+// DepositTxParams contains the input parameters of the deposit transaction.
+type DepositTxParams struct {
+	AccountID int64 `json:"account_id"`
+	//* in the account's currency
+	Amount int64 `json:"amount"`
+}
+
+// WithdrawTxParams contains the input parameters of the withdraw transaction.
+type WithdrawTxParams struct {
+	AccountID int64 `json:"account_id"`
+	//* in the account's currency
+	Amount int64 `json:"amount"`
+}
+
+// ClearingTxResult is the result of a deposit or a withdrawal.
+type ClearingTxResult struct {
+	Account         Account `json:"account"`
+	ClearingAccount Account `json:"clearing_account"`
+	Entry           Entry   `json:"entry"`
+	ClearingEntry   Entry   `json:"clearing_entry"`
+}
+
+// DepositTx credits an account with money from outside the bank.
+func (store *SQLStore) DepositTx(ctx context.Context, arg DepositTxParams) (ClearingTxResult, error) {
+	return store.clearingTx(ctx, arg.AccountID, arg.Amount)
+}
+
+// WithdrawTx debits an account for money leaving the bank, within its balance and overdraft limit.
+func (store *SQLStore) WithdrawTx(ctx context.Context, arg WithdrawTxParams) (ClearingTxResult, error) {
+	return store.clearingTx(ctx, arg.AccountID, -arg.Amount)
+}
+
+// clearingTx posts amount to the account and -amount to the clearing account of its currency
+func (store *SQLStore) clearingTx(ctx context.Context, accountID int64, amount int64) (ClearingTxResult, error) {
+	var result ClearingTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+
+		// 0) find the clearing account, the currency can't change so no lock needed yet
+
+		account, err := q.GetAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if account.Owner == ClearingAccountOwner {
+			return fmt.Errorf("%w: account [%d]", ErrClearingAccount, account.ID)
+		}
+
+		clearing, err := q.GetClearingAccount(ctx, account.Currency)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrNoClearingAccount, account.Currency)
+		}
+		if err != nil {
+			return err
+		}
+
+		// 1) lock both in id order, like TransferTx, and check the balance of a withdrawal
+
+		account, clearing, err = lockAccounts(ctx, q, account.ID, clearing.ID)
+		if err != nil {
+			return err
+		}
+		if amount < 0 && account.Balance+account.OverdraftLimit < -amount {
+			return fmt.Errorf("%w: account [%d] has %d available, withdrawal needs %d",
+				ErrInsufficientFunds, account.ID, account.Balance+account.OverdraftLimit, -amount)
+		}
+
+		// 2) the two entries
+
+		result.Entry, err = createChainedEntry(ctx, q, account, amount, sql.NullInt64{})
+		if err != nil {
+			return err
+		}
+
+		result.ClearingEntry, err = createChainedEntry(ctx, q, clearing, -amount, sql.NullInt64{})
+		if err != nil {
+			return err
+		}
+
+		// 3) update balances, smaller id first
+
+		if account.ID < clearing.ID {
+			result.Account, result.ClearingAccount, err = addMoney(ctx, q, result.Entry, result.ClearingEntry)
+		} else {
+			result.ClearingAccount, result.Account, err = addMoney(ctx, q, result.ClearingEntry, result.Entry)
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == accountsBalanceCheck {
+			return fmt.Errorf("%w: %v", ErrInsufficientFunds, pqErr)
+		}
+		return err
+	})
+
+	return result, err
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositTx(t *testing.T) {
+	account := createRandomAccountWithCurrency(t, util.USD)
+
+	result, err := testStore.DepositTx(context.Background(), DepositTxParams{
+		AccountID: account.ID,
+		Amount:    50,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, account.Balance+50, result.Account.Balance)
+	require.Equal(t, ClearingAccountOwner, result.ClearingAccount.Owner)
+	require.Equal(t, util.USD, result.ClearingAccount.Currency)
+
+	//* balanced double entry
+	require.Equal(t, account.ID, result.Entry.AccountID)
+	require.Equal(t, int64(50), result.Entry.Amount)
+	require.Equal(t, result.ClearingAccount.ID, result.ClearingEntry.AccountID)
+	require.Equal(t, int64(-50), result.ClearingEntry.Amount)
+	require.Equal(t, result.Account.Balance, result.Entry.BalanceAfter)
+	require.Equal(t, result.ClearingAccount.Balance, result.ClearingEntry.BalanceAfter)
+}
+
+func TestWithdrawTx(t *testing.T) {
+	account := createRandomAccountWithCurrency(t, util.EUR)
+
+	result, err := testStore.WithdrawTx(context.Background(), WithdrawTxParams{
+		AccountID: account.ID,
+		Amount:    account.Balance,
+	})
+	require.NoError(t, err)
+	require.Zero(t, result.Account.Balance)
+	require.Equal(t, -account.Balance, result.Entry.Amount)
+	require.Equal(t, account.Balance, result.ClearingEntry.Amount)
+
+	_, err = testStore.WithdrawTx(context.Background(), WithdrawTxParams{
+		AccountID: account.ID,
+		Amount:    1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestTransferTxClearingAccount(t *testing.T) {
+	account := createRandomAccountWithCurrency(t, util.USD)
+
+	clearing, err := testQueries.GetClearingAccount(context.Background(), util.USD)
+	require.NoError(t, err)
+
+	_, err = testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account.ID,
+		ToAccountID:   clearing.ID,
+		Amount:        1,
+	})
+	require.ErrorIs(t, err, ErrClearingAccount)
+
+	_, err = testStore.DepositTx(context.Background(), DepositTxParams{
+		AccountID: clearing.ID,
+		Amount:    1,
+	})
+	require.ErrorIs(t, err, ErrClearingAccount)
+}
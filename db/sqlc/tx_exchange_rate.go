@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// UpsertExchangeRatesTx writes a whole set of rates, if one of them fails none is changed.
+func (store *SQLStore) UpsertExchangeRatesTx(ctx context.Context, arg []UpsertExchangeRateParams) ([]ExchangeRate, error) {
+	var rates []ExchangeRate
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		for _, rate := range arg {
+			stored, err := q.UpsertExchangeRate(ctx, rate)
+			if err != nil {
+				return fmt.Errorf("cannot store rate %s/%s: %w", rate.FromCurrency, rate.ToCurrency, err)
+			}
+			rates = append(rates, stored)
+		}
+		return nil
+	})
+
+	return rates, err
+}
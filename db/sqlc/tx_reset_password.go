@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// ResetPasswordTxParams contains the hashed reset token and the new password hash.
+type ResetPasswordTxParams struct {
+	TokenHash         string    `json:"token_hash"`
+	HashedPassword    string    `json:"hashed_password"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+}
+
+// ResetPasswordTx uses up the reset token, stores the new password and blocks
+// every session of the user. an unknown, used or expired token returns sql.ErrNoRows.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		reset, err := q.UsePasswordReset(ctx, arg.TokenHash)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:          reset.Username,
+			HashedPassword:    arg.HashedPassword,
+			PasswordChangedAt: arg.PasswordChangedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.BlockUserSessions(ctx, user.Username)
+	})
+
+	return user, err
+}
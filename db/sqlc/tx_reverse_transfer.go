@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/lib/pq"
+)
+
+// ^ a transfer goes completed -> partially_reversed -> reversed, reversals themselves stay completed
+const (
+	TransferStatusCompleted         = "completed"
+	TransferStatusPartiallyReversed = "partially_reversed"
+	TransferStatusReversed          = "reversed"
+)
+
+// ErrTransferReversed is returned by ReverseTransferTx when nothing is left to reverse.
+var ErrTransferReversed = errors.New("transfer already fully reversed")
+
+// ErrReversalTooLarge is returned by ReverseTransferTx when the amount is more than is left to reverse.
+var ErrReversalTooLarge = errors.New("reversal exceeds the rest of the transfer")
+
+// ErrReverseReversal is returned by ReverseTransferTx for a transfer that is itself a reversal.
+var ErrReverseReversal = errors.New("a reversal cannot be reversed")
+
+// ReverseTransferTxParams contains the input parameters of the reverse transfer transaction.
+type ReverseTransferTxParams struct {
+	TransferID int64 `json:"transfer_id"`
+	//* in the currency of the original's source account, 0 reverses whatever is left
+	Amount int64 `json:"amount"`
+}
+
+// ReverseTransferTxResult is the result of the reverse transfer transaction.
+type ReverseTransferTxResult struct {
+	//* the original with its new status and reversed amount
+	Original Transfer `json:"original"`
+	//* the compensating transfer, from the original's destination back to its source
+	Reversal    Transfer `json:"reversal"`
+	FromAccount Account  `json:"from_account"`
+	ToAccount   Account  `json:"to_account"`
+	FromEntry   Entry    `json:"from_entry"`
+	ToEntry     Entry    `json:"to_entry"`
+}
+
+// ReverseTransferTx sends all or part of a transfer back with a compensating transfer linked to it.
+// The original's row lock is taken first, so concurrent reversals queue up and
+// together can never send back more than the original moved.
+func (store *SQLStore) ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error) {
+	var result ReverseTransferTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+
+		// 0) lock the original and work out what is left of it
+
+		original, err := q.GetTransferForUpdate(ctx, arg.TransferID)
+		if err != nil {
+			return err
+		}
+		if original.ReversalOf.Valid {
+			return fmt.Errorf("%w: transfer [%d] reverses [%d]", ErrReverseReversal, original.ID, original.ReversalOf.Int64)
+		}
+
+		remaining := original.Amount - original.ReversedAmount
+		if remaining == 0 {
+			return fmt.Errorf("%w: transfer [%d]", ErrTransferReversed, original.ID)
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount < 0 || amount > remaining {
+			return fmt.Errorf("%w: transfer [%d] has %d left to reverse, asked for %d",
+				ErrReversalTooLarge, original.ID, remaining, amount)
+		}
+
+		// 1) the destination gives back its share of to_amount, worked out on the running total
+		//    so partial reversals of a converted transfer add up to exactly to_amount
+
+		reversed := original.ReversedAmount + amount
+		debit := mulDiv(original.ToAmount, reversed, original.Amount) - mulDiv(original.ToAmount, original.ReversedAmount, original.Amount)
+		if debit < 1 {
+			return fmt.Errorf("%w: %d of transfer [%d]", ErrAmountTooSmall, amount, original.ID)
+		}
+
+		// 2) lock both accounts in id order, like TransferTx; frozen accounts are not checked, reversals are a banker's call
+
+		fromAccount, toAccount, err := lockAccounts(ctx, q, original.ToAccountID, original.FromAccountID)
+		if err != nil {
+			return err
+		}
+		if fromAccount.Balance+fromAccount.OverdraftLimit < debit {
+			return fmt.Errorf("%w: account [%d] has %d available, reversal needs %d",
+				ErrInsufficientFunds, fromAccount.ID, fromAccount.Balance+fromAccount.OverdraftLimit, debit)
+		}
+
+		// 3) the compensating transfer and its entries
+
+		result.Reversal, err = q.CreateTransfer(ctx, CreateTransferParams{
+			FromAccountID: original.ToAccountID,
+			ToAccountID:   original.FromAccountID,
+			Amount:        debit,
+			ToAmount:      amount,
+			ExchangeRate:  float64(amount) / float64(debit),
+			ReversalOf:    sql.NullInt64{Int64: original.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		reversalID := sql.NullInt64{Int64: result.Reversal.ID, Valid: true}
+		result.FromEntry, err = createChainedEntry(ctx, q, fromAccount, -debit, reversalID)
+		if err != nil {
+			return err
+		}
+
+		result.ToEntry, err = createChainedEntry(ctx, q, toAccount, amount, reversalID)
+		if err != nil {
+			return err
+		}
+
+		// 4) update balances, smaller id first
+
+		if original.ToAccountID < original.FromAccountID {
+			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, result.FromEntry, result.ToEntry)
+		} else {
+			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, result.ToEntry, result.FromEntry)
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == accountsBalanceCheck {
+			return fmt.Errorf("%w: %v", ErrInsufficientFunds, pqErr)
+		}
+		if err != nil {
+			return err
+		}
+
+		// 5) mark the original
+
+		status := TransferStatusPartiallyReversed
+		if reversed == original.Amount {
+			status = TransferStatusReversed
+		}
+
+		result.Original, err = q.UpdateTransferReversal(ctx, UpdateTransferReversalParams{
+			ID:             original.ID,
+			ReversedAmount: reversed,
+			Status:         status,
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// mulDiv returns a*b/c rounded down, big.Int keeps a*b from overflowing
+func mulDiv(a int64, b int64, c int64) int64 {
+	var product big.Int
+	product.Mul(big.NewInt(a), big.NewInt(b))
+	return product.Quo(&product, big.NewInt(c)).Int64()
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomTransferTx(t *testing.T, amount int64) (TransferTxResult, Account, Account) {
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccountWithCurrency(t, account1.Currency)
+
+	result, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusCompleted, result.Transfer.Status)
+	return result, account1, account2
+}
+
+func TestReverseTransferTxFull(t *testing.T) {
+	original, account1, account2 := createRandomTransferTx(t, 50)
+
+	result, err := testStore.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, TransferStatusReversed, result.Original.Status)
+	require.Equal(t, int64(50), result.Original.ReversedAmount)
+
+	reversal := result.Reversal
+	require.Equal(t, account2.ID, reversal.FromAccountID)
+	require.Equal(t, account1.ID, reversal.ToAccountID)
+	require.Equal(t, int64(50), reversal.Amount)
+	require.True(t, reversal.ReversalOf.Valid)
+	require.Equal(t, original.Transfer.ID, reversal.ReversalOf.Int64)
+
+	require.Equal(t, int64(-50), result.FromEntry.Amount)
+	require.Equal(t, int64(50), result.ToEntry.Amount)
+
+	//* both accounts are back where they started
+	require.Equal(t, account1.Balance, result.ToAccount.Balance)
+	require.Equal(t, account2.Balance, result.FromAccount.Balance)
+
+	_, err = testStore.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.ErrorIs(t, err, ErrTransferReversed)
+
+	_, err = testStore.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: reversal.ID,
+	})
+	require.ErrorIs(t, err, ErrReverseReversal)
+}
+
+func TestReverseTransferTxPartial(t *testing.T) {
+	original, _, _ := createRandomTransferTx(t, 50)
+
+	result, err := testStore.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     20,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusPartiallyReversed, result.Original.Status)
+	require.Equal(t, int64(20), result.Original.ReversedAmount)
+
+	_, err = testStore.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     31,
+	})
+	require.ErrorIs(t, err, ErrReversalTooLarge)
+
+	//* no amount reverses what is left
+	result, err = testStore.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(30), result.Reversal.Amount)
+	require.Equal(t, TransferStatusReversed, result.Original.Status)
+}
+
+func TestReverseTransferTxConcurrent(t *testing.T) {
+	original, account1, account2 := createRandomTransferTx(t, 50)
+
+	//* ten reversals of 10 race for a transfer of 50, exactly five may win
+	n := 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := testStore.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+				TransferID: original.Transfer.ID,
+				Amount:     10,
+			})
+			errs <- err
+		}()
+	}
+
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.ErrorIs(t, err, ErrTransferReversed)
+	}
+	require.Equal(t, 5, succeeded)
+
+	transfer, err := testQueries.GetTransfer(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferStatusReversed, transfer.Status)
+
+	updated1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updated1.Balance)
+
+	updated2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updated2.Balance)
+}
+
+func TestReverseTransferTxCrossCurrency(t *testing.T) {
+	account1 := createRandomAccountWithCurrency(t, util.USD)
+	account2 := createRandomAccountWithCurrency(t, util.INR)
+
+	_, err := testQueries.UpsertExchangeRate(context.Background(), UpsertExchangeRateParams{
+		FromCurrency: util.USD,
+		ToCurrency:   util.INR,
+		Rate:         3,
+	})
+	require.NoError(t, err)
+
+	original, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(30), original.Transfer.ToAmount)
+
+	//* thirds of a converted transfer round down, the last part picks up the remainder
+	var debited int64
+	for _, amount := range []int64{3, 3, 4} {
+		result, err := testStore.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+			TransferID: original.Transfer.ID,
+			Amount:     amount,
+		})
+		require.NoError(t, err)
+		require.Equal(t, amount, result.Reversal.ToAmount)
+		debited += result.Reversal.Amount
+	}
+	require.Equal(t, original.Transfer.ToAmount, debited)
+
+	updated2, err := testQueries.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updated2.Balance)
+}
+
+func TestMulDiv(t *testing.T) {
+	require.Equal(t, int64(9), mulDiv(30, 3, 10))
+	require.Equal(t, int64(3), mulDiv(10, 1, 3))
+	require.Equal(t, int64(1<<62), mulDiv(1<<62, 1<<62, 1<<62))
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// StatementTxParams contains the input parameters of the statement transaction.
+type StatementTxParams struct {
+	AccountID int64     `json:"account_id"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+}
+
+// StatementTxResult is a statement of an account over [From, To).
+// Entries are ordered by (created_at, id), the balances are the balance_after of the last entry before From and To.
+type StatementTxResult struct {
+	OpeningBalance int64                     `json:"opening_balance"`
+	TotalCredits   int64                     `json:"total_credits"`
+	TotalDebits    int64                     `json:"total_debits"`
+	ClosingBalance int64                     `json:"closing_balance"`
+	Entries        []ListStatementEntriesRow `json:"entries"`
+}
+
+// StatementTx reads the totals and the entries of a statement from one snapshot,
+// so an entry committed in between can't make them disagree.
+func (store *SQLStore) StatementTx(ctx context.Context, arg StatementTxParams) (StatementTxResult, error) {
+	var result StatementTxResult
+
+	tx, err := store.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return result, err
+	}
+	//* nothing was written, rolling back is all the cleanup needed
+	defer tx.Rollback()
+
+	q := New(tx)
+
+	summary, err := q.GetStatementSummary(ctx, GetStatementSummaryParams{
+		AccountID: arg.AccountID,
+		FromTime:  arg.From,
+		ToTime:    arg.To,
+	})
+	if err != nil {
+		return result, fmt.Errorf("cannot sum statement: %w", err)
+	}
+
+	result.Entries, err = q.ListStatementEntries(ctx, ListStatementEntriesParams{
+		AccountID: arg.AccountID,
+		FromTime:  arg.From,
+		ToTime:    arg.To,
+	})
+	if err != nil {
+		return result, fmt.Errorf("cannot list statement entries: %w", err)
+	}
+
+	result.OpeningBalance = summary.OpeningBalance
+	result.TotalCredits = summary.TotalCredits
+	result.TotalDebits = summary.TotalDebits
+	result.ClosingBalance = summary.ClosingBalance
+	return result, nil
+}
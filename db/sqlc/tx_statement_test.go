@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementTx(t *testing.T) {
+	account1 := createEmptyAccount(t)
+	account2 := createRandomAccountWithCurrency(t, util.USD)
+
+	_, err := testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account1.ID, Amount: 100})
+	require.NoError(t, err)
+
+	//* entries are stamped when they are posted, everything after this is in the statement
+	time.Sleep(10 * time.Millisecond)
+	from := time.Now()
+
+	_, err = testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account1.ID, Amount: 50})
+	require.NoError(t, err)
+	transfer, err := testStore.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        30,
+	})
+	require.NoError(t, err)
+
+	statement, err := testStore.StatementTx(context.Background(), StatementTxParams{
+		AccountID: account1.ID,
+		From:      from,
+		To:        time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, int64(100), statement.OpeningBalance)
+	require.Equal(t, int64(50), statement.TotalCredits)
+	require.Equal(t, int64(30), statement.TotalDebits)
+	require.Equal(t, int64(120), statement.ClosingBalance)
+	require.Equal(t, transfer.FromAccount.Balance, statement.ClosingBalance)
+
+	require.Len(t, statement.Entries, 2)
+	require.False(t, statement.Entries[0].TransferID.Valid)
+	require.False(t, statement.Entries[0].CounterpartyAccountID.Valid)
+	require.Equal(t, transfer.Transfer.ID, statement.Entries[1].TransferID.Int64)
+	require.Equal(t, account2.ID, statement.Entries[1].CounterpartyAccountID.Int64)
+	require.Equal(t, account2.Owner, statement.Entries[1].CounterpartyOwner.String)
+	require.Equal(t, int64(120), statement.Entries[1].BalanceAfter)
+}
+
+func TestStatementTxEmpty(t *testing.T) {
+	account := createEmptyAccount(t)
+
+	_, err := testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account.ID, Amount: 40})
+	require.NoError(t, err)
+
+	//* a period without entries still carries the balance through
+	statement, err := testStore.StatementTx(context.Background(), StatementTxParams{
+		AccountID: account.ID,
+		From:      time.Now().Add(time.Hour),
+		To:        time.Now().Add(2 * time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(40), statement.OpeningBalance)
+	require.Equal(t, int64(40), statement.ClosingBalance)
+	require.Empty(t, statement.Entries)
+}
+
+func TestStatementTxClosingBalance(t *testing.T) {
+	account := createEmptyAccount(t)
+
+	from := time.Now()
+	_, err := testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account.ID, Amount: 70})
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	to := time.Now()
+
+	//* posted after the statement ends, the closing balance is the one left by the last entry before it
+	_, err = testStore.DepositTx(context.Background(), DepositTxParams{AccountID: account.ID, Amount: 25})
+	require.NoError(t, err)
+
+	statement, err := testStore.StatementTx(context.Background(), StatementTxParams{
+		AccountID: account.ID,
+		From:      from,
+		To:        to,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), statement.OpeningBalance)
+	require.Equal(t, int64(70), statement.TotalCredits)
+	require.Equal(t, int64(70), statement.ClosingBalance)
+	require.Len(t, statement.Entries, 1)
+	require.Equal(t, statement.ClosingBalance, statement.Entries[0].BalanceAfter)
+}
@@ -0,0 +1,48 @@
+package db
+
+import "context"
+
+// SetTOTPTxParams contains the totp state to store for a user.
+type SetTOTPTxParams struct {
+	Username    string `json:"username"`
+	TotpSecret  []byte `json:"totp_secret"`
+	TotpEnabled bool   `json:"totp_enabled"`
+	//* replaces every recovery code the user had, empty just removes them
+	RecoveryCodeHashes []string `json:"recovery_code_hashes"`
+}
+
+// SetTOTPTx updates the user's totp secret and swaps out the recovery codes
+// in one transaction, so enabling never leaves a user without codes.
+func (store *SQLStore) SetTOTPTx(ctx context.Context, arg SetTOTPTxParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		user, err = q.UpdateUserTOTP(ctx, UpdateUserTOTPParams{
+			Username:    arg.Username,
+			TotpSecret:  arg.TotpSecret,
+			TotpEnabled: arg.TotpEnabled,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		for _, codeHash := range arg.RecoveryCodeHashes {
+			_, err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username: arg.Username,
+				CodeHash: codeHash,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return user, err
+}
@@ -0,0 +1,53 @@
+package db
+
+import "context"
+
+// UpdateUserTxParams contains the profile changes and the code for a new verification email.
+type UpdateUserTxParams struct {
+	UpdateUserParams
+	SecretCode string `json:"secret_code"`
+	//* only runs when the new email has to be verified, an error rolls the update back
+	AfterUpdate func(user User, verifyEmail VerifyEmail) error `json:"-"`
+}
+
+// UpdateUserTxResult is the result of the update user transaction.
+type UpdateUserTxResult struct {
+	User User `json:"user"`
+	//* nil unless the email changed
+	VerifyEmail *VerifyEmail `json:"verify_email"`
+}
+
+// UpdateUserTx updates the profile, a changed email is unverified again and gets a new verification email.
+func (store *SQLStore) UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error) {
+	var result UpdateUserTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.UpdateUser(ctx, arg.UpdateUserParams)
+		if err != nil {
+			return err
+		}
+
+		if !arg.Email.Valid || result.User.IsEmailVerified {
+			return nil
+		}
+
+		verifyEmail, err := q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:   result.User.Username,
+			Email:      result.User.Email,
+			SecretCode: arg.SecretCode,
+		})
+		if err != nil {
+			return err
+		}
+		result.VerifyEmail = &verifyEmail
+
+		if arg.AfterUpdate != nil {
+			return arg.AfterUpdate(result.User, verifyEmail)
+		}
+		return nil
+	})
+
+	return result, err
+}
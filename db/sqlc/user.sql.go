@@ -7,6 +7,8 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -17,7 +19,7 @@ INSERT INTO users(
     email
 ) VALUES (
     $1, $2, $3, $4
-) RETURNING username, hashed_password, full_name, email, password_changed_at, created_at
+) RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at
 `
 
 type CreateUserParams struct {
@@ -42,12 +44,19 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, hashed_password, full_name, email, password_changed_at, created_at FROM users
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at FROM users
 WHERE username = $1
 LIMIT 1
 `
@@ -62,6 +71,338 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at FROM users
+WHERE email = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const getUserForUpdate = `-- name: GetUserForUpdate :one
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at FROM users
+WHERE username = $1
+LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetUserForUpdate(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserForUpdate, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2,
+    password_changed_at = $3
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at
+`
+
+type UpdateUserPasswordParams struct {
+	Username          string    `json:"username"`
+	HashedPassword    string    `json:"hashed_password"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPassword, arg.Username, arg.HashedPassword, arg.PasswordChangedAt)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const updateUserTOTP = `-- name: UpdateUserTOTP :one
+UPDATE users
+SET totp_secret = $2,
+    totp_enabled = $3
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at
+`
+
+type UpdateUserTOTPParams struct {
+	Username    string `json:"username"`
+	TotpSecret  []byte `json:"totp_secret"`
+	TotpEnabled bool   `json:"totp_enabled"`
+}
+
+func (q *Queries) UpdateUserTOTP(ctx context.Context, arg UpdateUserTOTPParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserTOTP, arg.Username, arg.TotpSecret, arg.TotpEnabled)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const recordFailedLogin = `-- name: RecordFailedLogin :one
+UPDATE users
+SET failed_login_attempts = failed_login_attempts + 1,
+    locked_until = now() + interval '1 microsecond' * (
+      CASE
+        WHEN $1::int > 0 AND failed_login_attempts + 1 >= $1::int
+          THEN $2::bigint
+        WHEN $3::bigint <= 0
+          THEN 0
+        WHEN $2::bigint > 0
+          THEN LEAST($3::bigint << LEAST(failed_login_attempts, 20), $2::bigint)
+        ELSE $3::bigint << LEAST(failed_login_attempts, 20)
+      END
+    )
+WHERE username = $4
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at
+`
+
+type RecordFailedLoginParams struct {
+	MaxAttempts       int32  `json:"max_attempts"`
+	LockoutMicros     int64  `json:"lockout_micros"`
+	BackoffBaseMicros int64  `json:"backoff_base_micros"`
+	Username          string `json:"username"`
+}
+
+func (q *Queries) RecordFailedLogin(ctx context.Context, arg RecordFailedLoginParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, recordFailedLogin,
+		arg.MaxAttempts,
+		arg.LockoutMicros,
+		arg.BackoffBaseMicros,
+		arg.Username,
+	)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const unlockUser = `-- name: UnlockUser :one
+UPDATE users
+SET failed_login_attempts = 0,
+    locked_until = '0001-01-01 00:00:00Z'
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at
+`
+
+func (q *Queries) UnlockUser(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, unlockUser, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const rehashUserPassword = `-- name: RehashUserPassword :exec
+UPDATE users
+SET hashed_password = $1
+WHERE username = $2
+  AND hashed_password = $3
+`
+
+type RehashUserPasswordParams struct {
+	NewHashedPassword string `json:"new_hashed_password"`
+	Username          string `json:"username"`
+	OldHashedPassword string `json:"old_hashed_password"`
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, rehashUserPassword, arg.NewHashedPassword, arg.Username, arg.OldHashedPassword)
+	return err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = true
+WHERE username = $1 AND email = $2
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at
+`
+
+type VerifyUserEmailParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.Username, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET
+  full_name = COALESCE($1, full_name),
+  email = COALESCE($2, email),
+  is_email_verified = CASE
+    WHEN $2 IS NULL OR $2 = email THEN is_email_verified
+    ELSE false
+  END
+WHERE username = $3
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at
+`
+
+type UpdateUserParams struct {
+	FullName sql.NullString `json:"full_name"`
+	Email    sql.NullString `json:"email"`
+	Username string         `json:"username"`
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser, arg.FullName, arg.Email, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
+	)
+	return i, err
+}
+
+const deactivateUser = `-- name: DeactivateUser :one
+UPDATE users
+SET deactivated_at = now()
+WHERE username = $1
+  AND deactivated_at IS NULL
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, role, totp_secret, totp_enabled, failed_login_attempts, locked_until, is_email_verified, deactivated_at
+`
+
+func (q *Queries) DeactivateUser(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, deactivateUser, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.Role,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.IsEmailVerified,
+		&i.DeactivatedAt,
 	)
 	return i, err
 }
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -50,6 +51,7 @@ func CreateRandomUser(t *testing.T) User {
 
 	require.NotZero(t, user.PasswordChangedAt.IsZero())
 	require.NotZero(t, user.CreatedAt)
+	require.Equal(t, util.DepositorRole, user.Role)
 	return user
 
 }
@@ -58,3 +60,157 @@ func CreateTestUser(t *testing.T) {
 
 	CreateRandomUser(t)
 }
+
+func TestUpdateUserPassword(t *testing.T) {
+	user1 := CreateRandomUser(t)
+
+	hashPassword, err := util.HashedPassword(util.RandomString(10))
+	require.NoError(t, err)
+
+	arg := UpdateUserPasswordParams{
+		Username:          user1.Username,
+		HashedPassword:    hashPassword,
+		PasswordChangedAt: time.Now(),
+	}
+
+	user2, err := testQueries.UpdateUserPassword(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, user2.Username)
+	require.Equal(t, hashPassword, user2.HashedPassword)
+	require.WithinDuration(t, arg.PasswordChangedAt, user2.PasswordChangedAt, time.Second)
+	require.Equal(t, user1.Email, user2.Email)
+}
+
+func TestRecordFailedLoginAndUnlock(t *testing.T) {
+	user1 := CreateRandomUser(t)
+	require.Zero(t, user1.FailedLoginAttempts)
+
+	const lockout = 15 * time.Minute
+	arg := RecordFailedLoginParams{
+		MaxAttempts:       5,
+		LockoutMicros:     lockout.Microseconds(),
+		BackoffBaseMicros: time.Second.Microseconds(),
+		Username:          user1.Username,
+	}
+
+	//* the backoff doubles with every failure and turns into the lockout at the limit
+	wantDelays := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, lockout, lockout}
+	for i, want := range wantDelays {
+		user2, err := testQueries.RecordFailedLogin(context.Background(), arg)
+		require.NoError(t, err)
+		require.Equal(t, int32(i+1), user2.FailedLoginAttempts)
+		require.WithinDuration(t, time.Now().Add(want), user2.LockedUntil, time.Second)
+	}
+
+	user3, err := testQueries.UnlockUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Zero(t, user3.FailedLoginAttempts)
+	require.True(t, user3.LockedUntil.Before(time.Now()))
+}
+
+func TestRecordFailedLoginConcurrent(t *testing.T) {
+	user1 := CreateRandomUser(t)
+
+	arg := RecordFailedLoginParams{
+		MaxAttempts:       5,
+		LockoutMicros:     (15 * time.Minute).Microseconds(),
+		BackoffBaseMicros: time.Second.Microseconds(),
+		Username:          user1.Username,
+	}
+
+	n := 10
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := testQueries.RecordFailedLogin(context.Background(), arg)
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	//* every failure counted, so the account ends up locked
+	user2, err := testQueries.GetUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Equal(t, int32(n), user2.FailedLoginAttempts)
+	require.WithinDuration(t, time.Now().Add(15*time.Minute), user2.LockedUntil, time.Second)
+}
+
+func TestRehashUserPassword(t *testing.T) {
+	user1 := CreateRandomUser(t)
+
+	newHash, err := util.HashedPassword(util.RandomString(10))
+	require.NoError(t, err)
+
+	//* a stale old hash means the password changed meanwhile, nothing is written
+	err = testQueries.RehashUserPassword(context.Background(), RehashUserPasswordParams{
+		NewHashedPassword: newHash,
+		Username:          user1.Username,
+		OldHashedPassword: "stale",
+	})
+	require.NoError(t, err)
+
+	user2, err := testQueries.GetUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Equal(t, user1.HashedPassword, user2.HashedPassword)
+
+	err = testQueries.RehashUserPassword(context.Background(), RehashUserPasswordParams{
+		NewHashedPassword: newHash,
+		Username:          user1.Username,
+		OldHashedPassword: user1.HashedPassword,
+	})
+	require.NoError(t, err)
+
+	user3, err := testQueries.GetUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.Equal(t, newHash, user3.HashedPassword)
+	require.WithinDuration(t, user1.PasswordChangedAt, user3.PasswordChangedAt, time.Second)
+}
+
+func TestUpdateUserFullNameOnly(t *testing.T) {
+	user1 := CreateRandomUser(t)
+	newFullName := util.RandomOwner()
+
+	user2, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: user1.Username,
+		FullName: sql.NullString{String: newFullName, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newFullName, user2.FullName)
+	require.Equal(t, user1.Email, user2.Email)
+	require.Equal(t, user1.HashedPassword, user2.HashedPassword)
+}
+
+func TestUpdateUserEmailResetsVerification(t *testing.T) {
+	user1 := CreateRandomUser(t)
+	_, err := testQueries.VerifyUserEmail(context.Background(), VerifyUserEmailParams{
+		Username: user1.Username,
+		Email:    user1.Email,
+	})
+	require.NoError(t, err)
+
+	newEmail := util.RandomEmail()
+	user2, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: user1.Username,
+		Email:    sql.NullString{String: newEmail, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newEmail, user2.Email)
+	require.Equal(t, user1.FullName, user2.FullName)
+	require.False(t, user2.IsEmailVerified)
+}
+
+func TestDeactivateUser(t *testing.T) {
+	user1 := CreateRandomUser(t)
+	require.False(t, user1.DeactivatedAt.Valid)
+
+	user2, err := testQueries.DeactivateUser(context.Background(), user1.Username)
+	require.NoError(t, err)
+	require.True(t, user2.DeactivatedAt.Valid)
+	require.WithinDuration(t, time.Now(), user2.DeactivatedAt.Time, time.Second)
+
+	//* a second call finds nothing left to deactivate
+	_, err = testQueries.DeactivateUser(context.Background(), user1.Username)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
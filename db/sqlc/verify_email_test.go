@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomUserTx(t *testing.T) CreateUserTxResult {
+	hashedPassword, err := util.HashedPassword(util.RandomString(10))
+	require.NoError(t, err)
+
+	secretCode, err := util.NewSecretCode()
+	require.NoError(t, err)
+
+	var mailed VerifyEmail
+	result, err := testStore.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       util.RandomOwner(),
+			HashedPassword: hashedPassword,
+			FullName:       util.RandomOwner(),
+			Email:          util.RandomEmail(),
+		},
+		SecretCode: secretCode,
+		AfterCreate: func(user User, verifyEmail VerifyEmail) error {
+			mailed = verifyEmail
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	require.False(t, result.User.IsEmailVerified)
+	require.Equal(t, result.User.Email, result.VerifyEmail.Email)
+	require.Equal(t, secretCode, result.VerifyEmail.SecretCode)
+	require.False(t, result.VerifyEmail.IsUsed)
+	require.True(t, result.VerifyEmail.ExpiredAt.After(result.VerifyEmail.CreatedAt))
+	require.Equal(t, result.VerifyEmail, mailed)
+
+	return result
+}
+
+func TestCreateUserTxRollsBack(t *testing.T) {
+	hashedPassword, err := util.HashedPassword(util.RandomString(10))
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+	_, err = testStore.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       username,
+			HashedPassword: hashedPassword,
+			FullName:       util.RandomOwner(),
+			Email:          util.RandomEmail(),
+		},
+		SecretCode: util.RandomString(32),
+		AfterCreate: func(user User, verifyEmail VerifyEmail) error {
+			return sql.ErrConnDone
+		},
+	})
+	require.ErrorIs(t, err, sql.ErrConnDone)
+
+	_, err = testQueries.GetUser(context.Background(), username)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	created := createRandomUserTx(t)
+
+	arg := VerifyEmailTxParams{
+		EmailId:    created.VerifyEmail.ID,
+		SecretCode: "wrong",
+	}
+	_, err := testStore.VerifyEmailTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	arg.SecretCode = created.VerifyEmail.SecretCode
+	result, err := testStore.VerifyEmailTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, result.User.IsEmailVerified)
+	require.True(t, result.VerifyEmail.IsUsed)
+
+	//* the link works once
+	_, err = testStore.VerifyEmailTx(context.Background(), arg)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestVerifyEmailTxAfterEmailChange(t *testing.T) {
+	created := createRandomUserTx(t)
+
+	_, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Username: created.User.Username,
+		Email:    sql.NullString{String: util.RandomEmail(), Valid: true},
+	})
+	require.NoError(t, err)
+
+	//* the old link proves the old address, not the new one
+	_, err = testStore.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailId:    created.VerifyEmail.ID,
+		SecretCode: created.VerifyEmail.SecretCode,
+	})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	user, err := testQueries.GetUser(context.Background(), created.User.Username)
+	require.NoError(t, err)
+	require.False(t, user.IsEmailVerified)
+}
@@ -13,11 +13,46 @@ import (
 //& uses the mapstrcutre under the hood so we have to use it
 
 type Config struct {
-	DBDriver            string        `mapstructure:"DB_DRIVER"`
-	DBSource            string        `mapstructure:"DB_SOURCE"`
-	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
-	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
-	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	DBDriver               string        `mapstructure:"DB_DRIVER"`
+	DBSource               string        `mapstructure:"DB_SOURCE"`
+	ServerAddress          string        `mapstructure:"SERVER_ADDRESS"`
+	TokenType              string        `mapstructure:"TOKEN_TYPE"`
+	TokenSymmetricKey      string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	TokenPrivateKey        string        `mapstructure:"TOKEN_PRIVATE_KEY"`
+	TokenPublicKey         string        `mapstructure:"TOKEN_PUBLIC_KEY"`
+	TokenKeyID             string        `mapstructure:"TOKEN_KEY_ID"`
+	TokenVerificationKeys  string        `mapstructure:"TOKEN_VERIFICATION_KEYS"`
+	AccessTokenDuration    time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration   time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	RevocationCacheTTL     time.Duration `mapstructure:"REVOCATION_CACHE_TTL"`
+	MFAChallengeDuration   time.Duration `mapstructure:"MFA_CHALLENGE_DURATION"`
+	TOTPEncryptionKey      string        `mapstructure:"TOTP_ENCRYPTION_KEY"`
+	LoginMaxFailedAttempts int32         `mapstructure:"LOGIN_MAX_FAILED_ATTEMPTS"`
+	LoginLockoutDuration   time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginBackoffBase       time.Duration `mapstructure:"LOGIN_BACKOFF_BASE"`
+	LoginRateLimit         int           `mapstructure:"LOGIN_RATE_LIMIT"`
+	LoginRateWindow        time.Duration `mapstructure:"LOGIN_RATE_WINDOW"`
+	AppBaseURL             string        `mapstructure:"APP_BASE_URL"`
+	PasswordResetDuration  time.Duration `mapstructure:"PASSWORD_RESET_DURATION"`
+	ApiKeyMaxDuration      time.Duration `mapstructure:"API_KEY_MAX_DURATION"`
+	OAuthCodeDuration      time.Duration `mapstructure:"OAUTH_CODE_DURATION"`
+	OAuthRateLimit         int           `mapstructure:"OAUTH_RATE_LIMIT"`
+	OAuthRateWindow        time.Duration `mapstructure:"OAUTH_RATE_WINDOW"`
+	FXProviderType         string        `mapstructure:"FX_PROVIDER_TYPE"`
+	FXRatesFile            string        `mapstructure:"FX_RATES_FILE"`
+	FXRefreshInterval      time.Duration `mapstructure:"FX_REFRESH_INTERVAL"`
+	SchedulerInterval      time.Duration `mapstructure:"SCHEDULER_INTERVAL"`
+	TransferMaxAmounts     string        `mapstructure:"TRANSFER_MAX_AMOUNTS"`
+	TransferDailyAmounts   string        `mapstructure:"TRANSFER_DAILY_AMOUNTS"`
+	TransferDailyCount     int32         `mapstructure:"TRANSFER_DAILY_COUNT"`
+	ReconciliationInterval time.Duration `mapstructure:"RECONCILIATION_INTERVAL"`
+	MailerType             string        `mapstructure:"MAILER_TYPE"`
+	MailDir                string        `mapstructure:"MAIL_DIR"`
+	MailFrom               string        `mapstructure:"MAIL_FROM"`
+	SMTPHost               string        `mapstructure:"SMTP_HOST"`
+	SMTPPort               string        `mapstructure:"SMTP_PORT"`
+	SMTPUsername           string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword           string        `mapstructure:"SMTP_PASSWORD"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
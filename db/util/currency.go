@@ -7,6 +7,9 @@ const (
 	INR = "INR"
 )
 
+// Currencies lists every supported currency
+var Currencies = []string{USD, EUR, CAD, INR}
+
 func IsSupportedCurrency(curr string) bool {
 
 	switch curr {
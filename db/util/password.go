@@ -1,15 +1,169 @@
 package util
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrMismatchedPassword = errors.New("password does not match")
+	ErrUnknownHashFormat  = errors.New("unknown password hash format")
+)
+
+// ^ a PasswordHasher writes the algorithm and its parameters into the hash itself,
+// ^ so any stored hash can still be checked after the default changes
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, hashedPassword string) error
+	//* true when the hash was made by another algorithm or with other parameters
+	NeedsRehash(hashedPassword string) bool
+}
+
+// DefaultPasswordHasher is used for every new hash and is what old hashes get upgraded to
+var DefaultPasswordHasher PasswordHasher = DefaultArgon2idHasher
+
 // retur
 func HashedPassword(password string) (string, error) {
+	return DefaultPasswordHasher.Hash(password)
+}
+
+// CheckPassword works for every supported format, the algorithm is read from the hash
+func CheckPassword(password string, hashedPassword string) error {
+	hasher, err := hasherFor(hashedPassword)
+	if err != nil {
+		return err
+	}
+	return hasher.Verify(password, hashedPassword)
+}
+
+// PasswordNeedsRehash reports whether the hash should be replaced by one from DefaultPasswordHasher
+func PasswordNeedsRehash(hashedPassword string) bool {
+	return DefaultPasswordHasher.NeedsRehash(hashedPassword)
+}
+
+func hasherFor(hashedPassword string) (PasswordHasher, error) {
+	switch {
+	case strings.HasPrefix(hashedPassword, argon2idPrefix):
+		return Argon2idHasher{}, nil
+	case strings.HasPrefix(hashedPassword, "$2a$"),
+		strings.HasPrefix(hashedPassword, "$2b$"),
+		strings.HasPrefix(hashedPassword, "$2y$"):
+		return BcryptHasher{}, nil
+	}
+	return nil, ErrUnknownHashFormat
+}
+
+// ^ Argon2idHasher stores hashes in the PHC string format:
+// ^ $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+type Argon2idHasher struct {
+	Time       uint32
+	Memory     uint32 // KiB
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+// * the defaults from the x/crypto/argon2 docs for interactive logins
+var DefaultArgon2idHasher = Argon2idHasher{
+	Time:       1,
+	Memory:     64 * 1024,
+	Threads:    4,
+	KeyLength:  32,
+	SaltLength: 16,
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (hasher Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, hasher.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to hash pass: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, hasher.Time, hasher.Memory, hasher.Threads, hasher.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		hasher.Memory,
+		hasher.Time,
+		hasher.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify uses the parameters stored in the hash, not the ones on the hasher
+func (hasher Argon2idHasher) Verify(password string, hashedPassword string) error {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+func (hasher Argon2idHasher) NeedsRehash(hashedPassword string) bool {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return true
+	}
+	return params.Time != hasher.Time ||
+		params.Memory != hasher.Memory ||
+		params.Threads != hasher.Threads ||
+		uint32(len(key)) != hasher.KeyLength ||
+		uint32(len(salt)) != hasher.SaltLength
+}
+
+func decodeArgon2id(hashedPassword string) (params Argon2idHasher, salt []byte, key []byte, err error) {
+	//* "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		err = ErrUnknownHashFormat
+		return
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return
+	}
+	if version != argon2.Version {
+		err = fmt.Errorf("unsupported argon2 version %d", version)
+		return
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return
+	}
+	return
+}
+
+// ^ BcryptHasher is the original format, kept so existing hashes still verify
+type BcryptHasher struct {
+	Cost int
+}
+
+func (hasher BcryptHasher) Hash(password string) (string, error) {
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), hasher.Cost)
 
 	if err != nil {
 		return " ", fmt.Errorf("failed to hash pass: %w", err)
@@ -20,8 +174,15 @@ func HashedPassword(password string) (string, error) {
 
 }
 
-func CheckPassword(password string, hashedPassword string) error {
-
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+func (hasher BcryptHasher) Verify(password string, hashedPassword string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatchedPassword
+	}
+	return err
 }
 
+func (hasher BcryptHasher) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != hasher.Cost
+}
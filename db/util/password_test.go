@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -23,7 +24,7 @@ func TestPassword(t *testing.T) {
 	wrongPass := RandomString(10)
 	//we creaye a wrong password and check if it's hash is also eq
 	err = CheckPassword(wrongPass, hashedPass)
-	require.EqualError(t, err, bcrypt.ErrMismatchedHashAndPassword.Error())
+	require.EqualError(t, err, ErrMismatchedPassword.Error())
 
 	//we wnat to esnure that 2hashed password are diff
 	//i.e for a same password different hashes should be there
@@ -37,3 +38,43 @@ func TestPassword(t *testing.T) {
 	require.NotEqual(t, hashedPass, hashedPass2)
 
 }
+
+func TestLegacyBcryptPassword(t *testing.T) {
+	password := RandomString(10)
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	//* hashes from before argon2id still verify, and ask to be upgraded
+	require.NoError(t, CheckPassword(password, string(legacy)))
+	require.ErrorIs(t, CheckPassword(RandomString(10), string(legacy)), ErrMismatchedPassword)
+	require.True(t, PasswordNeedsRehash(string(legacy)))
+
+	upgraded, err := HashedPassword(password)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(upgraded, "$argon2id$v=19$m=65536,t=1,p=4$"))
+	require.False(t, PasswordNeedsRehash(upgraded))
+	require.NoError(t, CheckPassword(password, upgraded))
+}
+
+func TestArgon2idParameters(t *testing.T) {
+	password := RandomString(10)
+
+	//* a cheaper hash made with older settings still verifies, but is out of date
+	cheap := Argon2idHasher{Time: 1, Memory: 8 * 1024, Threads: 1, KeyLength: 32, SaltLength: 16}
+	hashed, err := cheap.Hash(password)
+	require.NoError(t, err)
+
+	require.NoError(t, CheckPassword(password, hashed))
+	require.False(t, cheap.NeedsRehash(hashed))
+	require.True(t, PasswordNeedsRehash(hashed))
+
+	//* the bcrypt cost is part of the hash too
+	stronger := BcryptHasher{Cost: bcrypt.MinCost + 1}
+	weak, err := BcryptHasher{Cost: bcrypt.MinCost}.Hash(password)
+	require.NoError(t, err)
+	require.True(t, stronger.NeedsRehash(weak))
+
+	require.ErrorIs(t, CheckPassword(password, "plaintext"), ErrUnknownHashFormat)
+	require.Error(t, CheckPassword(password, "$argon2id$v=19$m=x$salt$key"))
+}
@@ -0,0 +1,11 @@
+package util
+
+// ^ roles stored in users.role and carried inside the token payload
+const (
+	DepositorRole = "depositor"
+	BankerRole    = "banker"
+)
+
+// ^ not a user role: carried by the short lived token handed out after the
+// ^ password step of a two-factor login, it only opens /users/login/mfa
+const MFAChallengeRole = "mfa_challenge"
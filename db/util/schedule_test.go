@@ -0,0 +1,68 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntervalSchedule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	schedule, err := ParseSchedule("@every 24h", start)
+	require.NoError(t, err)
+
+	require.Equal(t, start, schedule.Next(start.Add(-time.Second)))
+	require.Equal(t, start.Add(24*time.Hour), schedule.Next(start))
+	//* counted from the start, not from when it was asked
+	require.Equal(t, start.Add(72*time.Hour), schedule.Next(start.Add(50*time.Hour+17*time.Minute)))
+
+	_, err = ParseSchedule("@every 10s", start)
+	require.Error(t, err)
+
+	_, err = ParseSchedule("@every often", start)
+	require.Error(t, err)
+}
+
+func TestCronSchedule(t *testing.T) {
+	from := time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC) // a wednesday
+
+	testCases := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "* * * * *", want: time.Date(2024, 1, 31, 10, 31, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", want: time.Date(2024, 1, 31, 10, 45, 0, 0, time.UTC)},
+		{spec: "0 9 * * *", want: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 0 1 * *", want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "@monthly", want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * 1-5", want: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * 0", want: time.Date(2024, 2, 4, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 12 29 2 *", want: time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{spec: "30 10,18 * * *", want: time.Date(2024, 1, 31, 18, 30, 0, 0, time.UTC)},
+		//* both day fields restricted, either one is enough
+		{spec: "0 0 15 * 5", want: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tc.spec, from)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, schedule.Next(from))
+		})
+	}
+}
+
+func TestCronScheduleNeverMatches(t *testing.T) {
+	schedule, err := ParseSchedule("0 0 30 2 *", time.Now())
+	require.NoError(t, err)
+	require.True(t, schedule.Next(time.Now()).IsZero())
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 7", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@sometimes"} {
+		_, err := ParseSchedule(spec, time.Now())
+		require.Error(t, err, spec)
+	}
+}
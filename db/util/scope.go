@@ -0,0 +1,19 @@
+package util
+
+// ^ scopes granted to an api key, a key can only reach the routes its scopes open
+const (
+	ScopeAccountsRead   = "accounts:read"
+	ScopeAccountsWrite  = "accounts:write"
+	ScopeTransfersWrite = "transfers:write"
+)
+
+func IsSupportedScope(scope string) bool {
+
+	switch scope {
+
+	case ScopeAccountsRead, ScopeAccountsWrite, ScopeTransfersWrite:
+		return true
+
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EncryptSecret seals plaintext with AES-GCM, the nonce is prepended to the output.
+// the key must be 16, 24 or 32 bytes
+func EncryptSecret(key string, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// DecryptSecret opens a value produced by EncryptSecret
+func DecryptSecret(key string, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// NewSecretCode returns a random url safe code for links sent by mail
+func NewSecretCode() (string, error) {
+	code := make([]byte, 24)
+	if _, err := rand.Read(code); err != nil {
+		return "", fmt.Errorf("failed to generate secret code: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+// HashSecretCode is what gets stored for codes that are mailed out,
+// they are random so a plain sha256 is enough
+func HashSecretCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// ^ api keys look like "sbk_<16 hex chars>.<secret>", the part before the dot
+// ^ is stored in clear so the key can be found, the whole key only as a hash.
+// ^ The prefix column is unique, 8 random bytes keep a collision out of reach like for client ids
+const apiKeyTag = "sbk_"
+
+// NewApiKey returns a new api key and its lookup prefix
+func NewApiKey() (prefix string, key string, err error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	secret, err := NewSecretCode()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = apiKeyTag + hex.EncodeToString(id)
+	return prefix, prefix + "." + secret, nil
+}
+
+// ApiKeyPrefix extracts the lookup prefix from a key sent by a client
+func ApiKeyPrefix(key string) (string, bool) {
+	prefix, secret, found := strings.Cut(key, ".")
+	if !found || !strings.HasPrefix(prefix, apiKeyTag) || len(secret) == 0 {
+		return "", false
+	}
+	return prefix, true
+}
+
+// NewClientID returns a random id for an oauth client
+func NewClientID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate client id: %w", err)
+	}
+	return "sbc_" + hex.EncodeToString(id), nil
+}
+
+// PKCEChallenge is the S256 code challenge of a PKCE code verifier (RFC 7636)
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
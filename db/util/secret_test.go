@@ -0,0 +1,36 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewApiKey(t *testing.T) {
+	prefix, key, err := NewApiKey()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key, prefix+"."))
+	require.Len(t, prefix, len("sbk_")+16)
+
+	gotPrefix, ok := ApiKeyPrefix(key)
+	require.True(t, ok)
+	require.Equal(t, prefix, gotPrefix)
+
+	_, otherKey, err := NewApiKey()
+	require.NoError(t, err)
+	require.NotEqual(t, key, otherKey)
+}
+
+func TestApiKeyPrefixInvalid(t *testing.T) {
+	for _, key := range []string{"", "sbk_abcd1234", "sbk_abcd1234.", "abcd1234.secret", "." + RandomString(10)} {
+		_, ok := ApiKeyPrefix(key)
+		require.False(t, ok, key)
+	}
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	//* the example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	require.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", PKCEChallenge(verifier))
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ^ RFC 6238 appendix B, SHA1 rows truncated to 6 digits
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, want := range vectors {
+		code, err := TOTPCode(secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, code)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	require.NoError(t, err)
+
+	require.True(t, ValidateTOTP(code, secret, now))
+	//* one period of drift either way is fine, two is not
+	require.True(t, ValidateTOTP(code, secret, now.Add(totpPeriod)))
+	require.True(t, ValidateTOTP(code, secret, now.Add(-totpPeriod)))
+	require.False(t, ValidateTOTP(code, secret, now.Add(3*totpPeriod)))
+
+	require.False(t, ValidateTOTP("", secret, now))
+	require.False(t, ValidateTOTP(code, "not base32!", now))
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Simple Bank", "alice", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/Simple Bank:alice", parsed.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	require.Equal(t, "Simple Bank", parsed.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		require.Len(t, code, 11)
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+
+	//* users may type the code in upper case or with spaces around it
+	require.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(" "+strings.ToUpper(codes[0])+" "))
+	require.NotEqual(t, HashRecoveryCode(codes[0]), HashRecoveryCode(codes[1]))
+}
+
+func TestEncryptSecret(t *testing.T) {
+	key := RandomString(32)
+	secret := []byte(RandomString(20))
+
+	sealed, err := EncryptSecret(key, secret)
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed), string(secret))
+
+	opened, err := DecryptSecret(key, sealed)
+	require.NoError(t, err)
+	require.Equal(t, secret, opened)
+
+	_, err = DecryptSecret(RandomString(32), sealed)
+	require.Error(t, err)
+
+	_, err = EncryptSecret("short", secret)
+	require.Error(t, err)
+}
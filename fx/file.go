@@ -0,0 +1,35 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ^ FileProvider reads the rates from a json file, for local setups without a feed
+// ^ the file holds a list like [{"from": "USD", "to": "EUR", "rate": 0.92, "spread_bps": 50}]
+type FileProvider struct {
+	path string
+}
+
+func NewFileProvider(path string) (*FileProvider, error) {
+	if path == "" {
+		return nil, fmt.Errorf("FX_RATES_FILE is required for the file fx provider")
+	}
+	return &FileProvider{path: path}, nil
+}
+
+// * the file is read on every call so edits show up on the next refresh
+func (provider *FileProvider) Rates(ctx context.Context) ([]Rate, error) {
+	data, err := os.ReadFile(provider.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []Rate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", provider.path, err)
+	}
+	return rates, nil
+}
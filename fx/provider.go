@@ -0,0 +1,113 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+)
+
+// ^ values accepted by FX_PROVIDER_TYPE in app.env
+const (
+	TypeNone = "none"
+	TypeFile = "file"
+)
+
+// Rate is one quote, Rate units of To for one unit of From
+type Rate struct {
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	Rate      float64 `json:"rate"`
+	SpreadBps int32   `json:"spread_bps"`
+}
+
+// RateProvider hands out the current rates, the implementation decides where they come from
+type RateProvider interface {
+	Rates(ctx context.Context) ([]Rate, error)
+}
+
+// RateStore is the part of db.Store the rates are written to
+type RateStore interface {
+	UpsertExchangeRatesTx(ctx context.Context, arg []db.UpsertExchangeRateParams) ([]db.ExchangeRate, error)
+}
+
+// NewProviderFromConfig builds the provider selected by FX_PROVIDER_TYPE,
+// without one the exchange_rates table is only changed by hand and nil is returned
+func NewProviderFromConfig(config util.Config) (RateProvider, error) {
+
+	switch config.FXProviderType {
+	case "", TypeNone:
+		return nil, nil
+
+	case TypeFile:
+		return NewFileProvider(config.FXRatesFile)
+	}
+
+	return nil, fmt.Errorf("unsupported fx provider type %q", config.FXProviderType)
+}
+
+// Sync copies the provider's rates into the exchange_rates table, transfers only read the table
+func Sync(ctx context.Context, provider RateProvider, store RateStore) error {
+	rates, err := provider.Rates(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot load rates: %w", err)
+	}
+
+	//* check everything first so a bad feed doesn't leave half the table updated
+	for _, rate := range rates {
+		if err := validateRate(rate); err != nil {
+			return err
+		}
+	}
+
+	arg := make([]db.UpsertExchangeRateParams, 0, len(rates))
+	for _, rate := range rates {
+		arg = append(arg, db.UpsertExchangeRateParams{
+			FromCurrency: rate.From,
+			ToCurrency:   rate.To,
+			Rate:         rate.Rate,
+			SpreadBps:    rate.SpreadBps,
+		})
+	}
+
+	//* and write them in one transaction, a rate the database still refuses rolls back the others
+	_, err = store.UpsertExchangeRatesTx(ctx, arg)
+	return err
+}
+
+// Refresh runs Sync every interval until the context is done, failures are logged
+// and the previous rates stay in place
+func Refresh(ctx context.Context, provider RateProvider, store RateStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := Sync(ctx, provider, store); err != nil {
+				log.Printf("fx refresh failed: %v", err)
+			}
+		}
+	}
+}
+
+func validateRate(rate Rate) error {
+	if !util.IsSupportedCurrency(rate.From) || !util.IsSupportedCurrency(rate.To) {
+		return fmt.Errorf("unsupported currency pair %s/%s", rate.From, rate.To)
+	}
+	if rate.From == rate.To {
+		return fmt.Errorf("rate from %s to itself", rate.From)
+	}
+	if rate.Rate <= 0 {
+		return fmt.Errorf("rate %s/%s must be positive", rate.From, rate.To)
+	}
+	if rate.SpreadBps < 0 || rate.SpreadBps >= 10000 {
+		return fmt.Errorf("spread of %s/%s must be between 0 and 9999 bps", rate.From, rate.To)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package fx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingStore struct {
+	upserts []db.UpsertExchangeRateParams
+}
+
+func (store *recordingStore) UpsertExchangeRatesTx(ctx context.Context, arg []db.UpsertExchangeRateParams) ([]db.ExchangeRate, error) {
+	var rates []db.ExchangeRate
+	for _, rate := range arg {
+		store.upserts = append(store.upserts, rate)
+		rates = append(rates, db.ExchangeRate{FromCurrency: rate.FromCurrency, ToCurrency: rate.ToCurrency, Rate: rate.Rate, SpreadBps: rate.SpreadBps})
+	}
+	return rates, nil
+}
+
+func writeRates(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestFileProvider(t *testing.T) {
+	path := writeRates(t, `[{"from": "USD", "to": "EUR", "rate": 0.92, "spread_bps": 50}]`)
+
+	provider, err := NewFileProvider(path)
+	require.NoError(t, err)
+
+	rates, err := provider.Rates(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Rate{{From: util.USD, To: util.EUR, Rate: 0.92, SpreadBps: 50}}, rates)
+
+	_, err = NewFileProvider("")
+	require.Error(t, err)
+}
+
+func TestSync(t *testing.T) {
+	provider, err := NewFileProvider(writeRates(t, `[
+		{"from": "USD", "to": "EUR", "rate": 0.92, "spread_bps": 50},
+		{"from": "EUR", "to": "USD", "rate": 1.08}
+	]`))
+	require.NoError(t, err)
+
+	store := &recordingStore{}
+	require.NoError(t, Sync(context.Background(), provider, store))
+	require.Len(t, store.upserts, 2)
+	require.Equal(t, db.UpsertExchangeRateParams{FromCurrency: util.EUR, ToCurrency: util.USD, Rate: 1.08}, store.upserts[1])
+}
+
+func TestSyncRejectsBadFeed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+	}{
+		{name: "UnsupportedCurrency", content: `[{"from": "USD", "to": "XYZ", "rate": 1}]`},
+		{name: "SameCurrency", content: `[{"from": "USD", "to": "USD", "rate": 1}]`},
+		{name: "ZeroRate", content: `[{"from": "USD", "to": "EUR", "rate": 0}]`},
+		{name: "SpreadTooLarge", content: `[{"from": "USD", "to": "EUR", "rate": 1, "spread_bps": 10000}]`},
+		{name: "NotJSON", content: `USD,EUR,0.92`},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			//* a good rate before the bad one must not be written either
+			content := tc.content
+			if content[0] == '[' {
+				content = `[{"from": "EUR", "to": "USD", "rate": 1.08},` + content[1:]
+			}
+
+			provider, err := NewFileProvider(writeRates(t, content))
+			require.NoError(t, err)
+
+			store := &recordingStore{}
+			require.Error(t, Sync(context.Background(), provider, store))
+			require.Empty(t, store.upserts)
+		})
+	}
+}
+
+func TestNewProviderFromConfig(t *testing.T) {
+	provider, err := NewProviderFromConfig(util.Config{})
+	require.NoError(t, err)
+	require.Nil(t, provider)
+
+	provider, err = NewProviderFromConfig(util.Config{FXProviderType: TypeFile, FXRatesFile: "rates.json"})
+	require.NoError(t, err)
+	require.IsType(t, &FileProvider{}, provider)
+
+	_, err = NewProviderFromConfig(util.Config{FXProviderType: "carrier-pigeon"})
+	require.Error(t, err)
+}
+
+func TestBundledRates(t *testing.T) {
+	provider, err := NewFileProvider("rates.json")
+	require.NoError(t, err)
+	require.NoError(t, Sync(context.Background(), provider, &recordingStore{}))
+}
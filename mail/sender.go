@@ -0,0 +1,111 @@
+package mail
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+)
+
+// ^ values accepted by MAILER_TYPE in app.env
+const (
+	TypeLog  = "log"
+	TypeFile = "file"
+	TypeSMTP = "smtp"
+)
+
+// EmailSender delivers a message, the implementation decides where it ends up
+type EmailSender interface {
+	SendEmail(subject string, content string, to []string) error
+}
+
+// NewSenderFromConfig builds the sender selected by MAILER_TYPE,
+// an empty type writes mails to stdout so local setups need nothing extra
+func NewSenderFromConfig(config util.Config) (EmailSender, error) {
+
+	switch config.MailerType {
+	case "", TypeLog:
+		return NewLogSender(os.Stdout), nil
+
+	case TypeFile:
+		return NewFileSender(config.MailDir)
+
+	case TypeSMTP:
+		return NewSMTPSender(
+			config.SMTPHost,
+			config.SMTPPort,
+			config.SMTPUsername,
+			config.SMTPPassword,
+			config.MailFrom,
+		), nil
+	}
+
+	return nil, fmt.Errorf("unsupported mailer type %q", config.MailerType)
+}
+
+// ^ LogSender writes every mail to a writer instead of sending it
+type LogSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogSender(w io.Writer) *LogSender {
+	return &LogSender{w: w}
+}
+
+func (sender *LogSender) SendEmail(subject string, content string, to []string) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	_, err := io.WriteString(sender.w, formatMessage("", subject, content, to))
+	return err
+}
+
+// ^ FileSender drops every mail as a .eml file into a directory
+type FileSender struct {
+	mu    sync.Mutex
+	dir   string
+	count int
+}
+
+func NewFileSender(dir string) (*FileSender, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("MAIL_DIR is required for the file mailer")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create mail dir: %w", err)
+	}
+	return &FileSender{dir: dir}, nil
+}
+
+func (sender *FileSender) SendEmail(subject string, content string, to []string) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	//* the counter keeps names unique when two mails share a timestamp
+	sender.count++
+	name := fmt.Sprintf("%d-%03d.eml", time.Now().UnixNano(), sender.count)
+
+	return os.WriteFile(filepath.Join(sender.dir, name), []byte(formatMessage("", subject, content, to)), 0o644)
+}
+
+// * a plain RFC 5322 message, good enough for the html bodies we send
+func formatMessage(from string, subject string, content string, to []string) string {
+	var sb strings.Builder
+	if from != "" {
+		fmt.Fprintf(&sb, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&sb, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&sb, "Subject: %s\r\n", subject)
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(content)
+	sb.WriteString("\r\n")
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogSender(t *testing.T) {
+	var out bytes.Buffer
+	sender := NewLogSender(&out)
+
+	err := sender.SendEmail("hello", "<b>hi</b>", []string{"a@test.com", "b@test.com"})
+	require.NoError(t, err)
+
+	require.Contains(t, out.String(), "To: a@test.com, b@test.com\r\n")
+	require.Contains(t, out.String(), "Subject: hello\r\n")
+	require.Contains(t, out.String(), "\r\n\r\n<b>hi</b>")
+}
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+
+	sender, err := NewFileSender(dir)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, sender.SendEmail("hello", "body", []string{"a@test.com"}))
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Contains(t, string(data), "Subject: hello")
+
+	_, err = NewFileSender("")
+	require.Error(t, err)
+}
+
+func TestNewSenderFromConfig(t *testing.T) {
+	sender, err := NewSenderFromConfig(util.Config{})
+	require.NoError(t, err)
+	require.IsType(t, &LogSender{}, sender)
+
+	sender, err = NewSenderFromConfig(util.Config{MailerType: TypeFile, MailDir: t.TempDir()})
+	require.NoError(t, err)
+	require.IsType(t, &FileSender{}, sender)
+
+	sender, err = NewSenderFromConfig(util.Config{MailerType: TypeSMTP, SMTPHost: "localhost", SMTPPort: "25"})
+	require.NoError(t, err)
+	require.IsType(t, &SMTPSender{}, sender)
+
+	//* rejected before any connection is made
+	err = sender.SendEmail("hi\r\nBcc: x@test.com", "body", []string{"a@test.com"})
+	require.Error(t, err)
+
+	_, err = NewSenderFromConfig(util.Config{MailerType: "pigeon"})
+	require.Error(t, err)
+}
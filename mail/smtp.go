@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// ^ SMTPSender is the real sender, it authenticates with PLAIN auth
+type SMTPSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPSender(host string, port string, username string, password string, from string) *SMTPSender {
+	return &SMTPSender{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (sender *SMTPSender) SendEmail(subject string, content string, to []string) error {
+	for _, value := range append([]string{subject}, to...) {
+		//* header injection guard, none of these may span lines
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid mail header value %q", value)
+		}
+	}
+
+	var auth smtp.Auth
+	if sender.username != "" {
+		auth = smtp.PlainAuth("", sender.username, sender.password, sender.host)
+	}
+
+	msg := formatMessage(sender.from, subject, content, to)
+	return smtp.SendMail(net.JoinHostPort(sender.host, sender.port), auth, sender.from, to, []byte(msg))
+}
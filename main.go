@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"log"
+	"os"
+	"strconv"
 
 	"github.com/itsadijmbt/simple_bank/api"
 	db "github.com/itsadijmbt/simple_bank/db/sqlc"
 	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/itsadijmbt/simple_bank/fx"
+	"github.com/itsadijmbt/simple_bank/reconciliation"
+	"github.com/itsadijmbt/simple_bank/scheduler"
 	_ "github.com/lib/pq"
 )
 
@@ -25,6 +32,46 @@ func main() {
 
 	store := db.NewStore(conn)
 
+	//* subcommands run once against the database and exit non-zero if they find anything wrong
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "reconcile":
+			reconcile(store)
+		case "verify-entries":
+			verifyEntries(store, os.Args[2:])
+		default:
+			log.Fatalf("unknown command %q, want reconcile or verify-entries", os.Args[1])
+		}
+		return
+	}
+
+	//* load the exchange rates before taking traffic, then keep them fresh in the background
+	rateProvider, err := fx.NewProviderFromConfig(config)
+	if err != nil {
+		log.Fatal("cannot create fx provider: ", err)
+	}
+	if rateProvider != nil {
+		if err := fx.Sync(context.Background(), rateProvider, store); err != nil {
+			log.Fatal("cannot sync exchange rates: ", err)
+		}
+		if config.FXRefreshInterval > 0 {
+			go fx.Refresh(context.Background(), rateProvider, store, config.FXRefreshInterval)
+		}
+	}
+
+	//* every replica runs a scheduler, they split the due standing orders between them
+	if config.SchedulerInterval > 0 {
+		limits, err := db.TransferLimitsFromConfig(config)
+		if err != nil {
+			log.Fatal("invalid transfer limits: ", err)
+		}
+		go scheduler.New(store, config.SchedulerInterval, limits).Run(context.Background())
+	}
+
+	if config.ReconciliationInterval > 0 {
+		go reconciliation.New(store, config.ReconciliationInterval).Run(context.Background())
+	}
+
 	server, err := api.NewServer(config, store)
 
 	if err != nil {
@@ -36,3 +83,59 @@ func main() {
 		log.Fatal("cannot start server")
 	}
 }
+
+func reconcile(store db.Store) {
+	run, err := reconciliation.New(store, 0).RunOnce(context.Background())
+	if err != nil {
+		log.Fatal("cannot reconcile: ", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(run); err != nil {
+		log.Fatal("cannot print reconciliation run: ", err)
+	}
+
+	if reconciliation.HasIssues(run) {
+		os.Exit(1)
+	}
+}
+
+// verifyEntries walks the entry chain of the given accounts, or of every account, and prints the broken ones
+func verifyEntries(store db.Store, args []string) {
+	var accountIDs []int64
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid account id %q", arg)
+		}
+		accountIDs = append(accountIDs, id)
+	}
+	if len(accountIDs) == 0 {
+		var err error
+		accountIDs, err = store.ListAccountIDs(context.Background())
+		if err != nil {
+			log.Fatal("cannot list accounts: ", err)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	broken := 0
+	for _, id := range accountIDs {
+		result, err := db.VerifyEntryChain(context.Background(), store, id)
+		if err != nil {
+			log.Fatalf("cannot verify entries of account %d: %v", id, err)
+		}
+		if !result.Valid {
+			broken++
+			if err := encoder.Encode(result); err != nil {
+				log.Fatal("cannot print verification: ", err)
+			}
+		}
+	}
+
+	log.Printf("verified %d accounts, %d broken", len(accountIDs), broken)
+	if broken > 0 {
+		os.Exit(1)
+	}
+}
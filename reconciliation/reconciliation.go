@@ -0,0 +1,167 @@
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+)
+
+// ^ Reconciler checks the ledger invariants and records what it found in reconciliation_runs:
+// ^   every account's balance is the sum of its entries,
+// ^   every transfer has one debit of amount on its source and one credit of to_amount on its destination.
+// ^ It only reads the ledger, so replicas running it at the same time just record one run each.
+type Reconciler struct {
+	store    db.Store
+	interval time.Duration
+	now      func() time.Time
+}
+
+func New(store db.Store, interval time.Duration) *Reconciler {
+	return &Reconciler{
+		store:    store,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Findings is what a run stores in reconciliation_runs.findings
+type Findings struct {
+	DriftedAccounts     []AccountDrift      `json:"drifted_accounts"`
+	OrphanedTransfers   []TransferImbalance `json:"orphaned_transfers"`
+	UnbalancedTransfers []TransferImbalance `json:"unbalanced_transfers"`
+}
+
+// AccountDrift is an account whose balance moved without entries, or the other way round
+type AccountDrift struct {
+	AccountID    int64 `json:"account_id"`
+	Balance      int64 `json:"balance"`
+	EntriesTotal int64 `json:"entries_total"`
+	//* balance - entries_total
+	Drift int64 `json:"drift"`
+}
+
+// TransferImbalance is a transfer whose entries don't match it
+type TransferImbalance struct {
+	TransferID    int64 `json:"transfer_id"`
+	FromAccountID int64 `json:"from_account_id"`
+	ToAccountID   int64 `json:"to_account_id"`
+	Amount        int64 `json:"amount"`
+	ToAmount      int64 `json:"to_amount"`
+	EntryCount    int32 `json:"entry_count"`
+	//* sums of its entries on each side, -amount and to_amount when balanced
+	Debited  int64 `json:"debited"`
+	Credited int64 `json:"credited"`
+}
+
+// Run reconciles every interval until the context is done
+func (reconciler *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(reconciler.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := reconciler.RunOnce(ctx); err != nil {
+				log.Printf("reconciliation: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce checks the whole ledger and records the run, issues are findings not errors
+func (reconciler *Reconciler) RunOnce(ctx context.Context) (db.ReconciliationRun, error) {
+	startedAt := reconciler.now()
+
+	counts, err := reconciler.store.CountLedger(ctx)
+	if err != nil {
+		return db.ReconciliationRun{}, fmt.Errorf("cannot count ledger: %w", err)
+	}
+
+	findings, err := reconciler.check(ctx)
+	if err != nil {
+		return db.ReconciliationRun{}, err
+	}
+
+	body, err := json.Marshal(findings)
+	if err != nil {
+		return db.ReconciliationRun{}, err
+	}
+
+	run, err := reconciler.store.CreateReconciliationRun(ctx, db.CreateReconciliationRunParams{
+		AccountsChecked:     counts.Accounts,
+		TransfersChecked:    counts.Transfers,
+		DriftedAccounts:     int32(len(findings.DriftedAccounts)),
+		OrphanedTransfers:   int32(len(findings.OrphanedTransfers)),
+		UnbalancedTransfers: int32(len(findings.UnbalancedTransfers)),
+		Findings:            body,
+		StartedAt:           startedAt,
+	})
+	if err != nil {
+		return db.ReconciliationRun{}, fmt.Errorf("cannot record reconciliation run: %w", err)
+	}
+
+	if HasIssues(run) {
+		log.Printf("reconciliation run %d: %d drifted accounts, %d orphaned and %d unbalanced transfers",
+			run.ID, run.DriftedAccounts, run.OrphanedTransfers, run.UnbalancedTransfers)
+	}
+	return run, nil
+}
+
+// HasIssues tells whether a run found anything wrong
+func HasIssues(run db.ReconciliationRun) bool {
+	return run.DriftedAccounts > 0 || run.OrphanedTransfers > 0 || run.UnbalancedTransfers > 0
+}
+
+func (reconciler *Reconciler) check(ctx context.Context) (Findings, error) {
+	//* empty lists rather than null in the stored json
+	findings := Findings{
+		DriftedAccounts:     []AccountDrift{},
+		OrphanedTransfers:   []TransferImbalance{},
+		UnbalancedTransfers: []TransferImbalance{},
+	}
+
+	//* each check is a single statement, and a transfer commits its balances and entries together,
+	//* so a transfer running concurrently never shows up as drift
+	drift, err := reconciler.store.ListAccountDrift(ctx)
+	if err != nil {
+		return findings, fmt.Errorf("cannot check account balances: %w", err)
+	}
+	for _, row := range drift {
+		findings.DriftedAccounts = append(findings.DriftedAccounts, AccountDrift{
+			AccountID:    row.ID,
+			Balance:      row.Balance,
+			EntriesTotal: row.EntriesTotal,
+			Drift:        row.Balance - row.EntriesTotal,
+		})
+	}
+
+	unbalanced, err := reconciler.store.ListUnbalancedTransfers(ctx)
+	if err != nil {
+		return findings, fmt.Errorf("cannot check transfers: %w", err)
+	}
+	for _, row := range unbalanced {
+		imbalance := TransferImbalance{
+			TransferID:    row.ID,
+			FromAccountID: row.FromAccountID,
+			ToAccountID:   row.ToAccountID,
+			Amount:        row.Amount,
+			ToAmount:      row.ToAmount,
+			EntryCount:    row.EntryCount,
+			Debited:       row.Debited,
+			Credited:      row.Credited,
+		}
+		if row.EntryCount == 0 {
+			findings.OrphanedTransfers = append(findings.OrphanedTransfers, imbalance)
+		} else {
+			findings.UnbalancedTransfers = append(findings.UnbalancedTransfers, imbalance)
+		}
+	}
+
+	return findings, nil
+}
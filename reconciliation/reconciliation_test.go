@@ -0,0 +1,97 @@
+package reconciliation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	mockdb "github.com/itsadijmbt/simple_bank/db/mock"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CountLedger(gomock.Any()).
+		Times(1).
+		Return(db.CountLedgerRow{Accounts: 10, Transfers: 20}, nil)
+	store.EXPECT().
+		ListAccountDrift(gomock.Any()).
+		Times(1).
+		Return([]db.ListAccountDriftRow{{ID: 3, Balance: 150, EntriesTotal: 100}}, nil)
+	store.EXPECT().
+		ListUnbalancedTransfers(gomock.Any()).
+		Times(1).
+		Return([]db.ListUnbalancedTransfersRow{
+			{ID: 7, FromAccountID: 1, ToAccountID: 2, Amount: 10, ToAmount: 10},
+			{ID: 8, FromAccountID: 1, ToAccountID: 2, Amount: 10, ToAmount: 10, EntryCount: 1, Debited: -10},
+		}, nil)
+
+	var stored db.CreateReconciliationRunParams
+	store.EXPECT().
+		CreateReconciliationRun(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateReconciliationRunParams) (db.ReconciliationRun, error) {
+			stored = arg
+			return db.ReconciliationRun{
+				ID:                  1,
+				AccountsChecked:     arg.AccountsChecked,
+				TransfersChecked:    arg.TransfersChecked,
+				DriftedAccounts:     arg.DriftedAccounts,
+				OrphanedTransfers:   arg.OrphanedTransfers,
+				UnbalancedTransfers: arg.UnbalancedTransfers,
+				Findings:            arg.Findings,
+				StartedAt:           arg.StartedAt,
+			}, nil
+		})
+
+	reconciler := New(store, time.Hour)
+	reconciler.now = func() time.Time { return now }
+
+	run, err := reconciler.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.True(t, HasIssues(run))
+
+	require.Equal(t, int64(10), stored.AccountsChecked)
+	require.Equal(t, int64(20), stored.TransfersChecked)
+	require.Equal(t, int32(1), stored.DriftedAccounts)
+	require.Equal(t, int32(1), stored.OrphanedTransfers)
+	require.Equal(t, int32(1), stored.UnbalancedTransfers)
+	require.Equal(t, now, stored.StartedAt)
+
+	var findings Findings
+	require.NoError(t, json.Unmarshal(stored.Findings, &findings))
+	require.Equal(t, []AccountDrift{{AccountID: 3, Balance: 150, EntriesTotal: 100, Drift: 50}}, findings.DriftedAccounts)
+	require.Equal(t, int64(7), findings.OrphanedTransfers[0].TransferID)
+	require.Equal(t, int64(8), findings.UnbalancedTransfers[0].TransferID)
+}
+
+func TestRunOnceClean(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().CountLedger(gomock.Any()).Times(1)
+	store.EXPECT().ListAccountDrift(gomock.Any()).Times(1)
+	store.EXPECT().ListUnbalancedTransfers(gomock.Any()).Times(1)
+	store.EXPECT().
+		CreateReconciliationRun(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateReconciliationRunParams) (db.ReconciliationRun, error) {
+			//* no nulls in the stored findings
+			require.JSONEq(t, `{"drifted_accounts":[],"orphaned_transfers":[],"unbalanced_transfers":[]}`, string(arg.Findings))
+			return db.ReconciliationRun{ID: 1, Findings: arg.Findings}, nil
+		})
+
+	run, err := New(store, time.Hour).RunOnce(context.Background())
+	require.NoError(t, err)
+	require.False(t, HasIssues(run))
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+)
+
+const (
+	//* how many standing orders one replica claims per poll
+	batchSize = 10
+	//* a replica that dies mid-run leaves its claims behind, others take them over after this
+	leaseDuration = 5 * time.Minute
+)
+
+// IdempotencyKeyPrefix starts the idempotency key of every run, it shares the owner's
+// keys with Idempotency-Key headers so the api refuses client keys that start with it
+const IdempotencyKeyPrefix = "scheduled_transfer:"
+
+// ^ Scheduler runs due standing orders from inside the server process. Every replica runs one:
+// ^ claims are taken with SKIP LOCKED plus a lease so two replicas never pick the same order,
+// ^ and each run goes through TransferTx under an idempotency key for that occurrence,
+// ^ so a run retried after a crash can't move the money a second time.
+type Scheduler struct {
+	store    db.Store
+	interval time.Duration
+	//* standing orders count towards the same limits as transfers made by hand
+	limits db.TransferLimitsByCurrency
+	now    func() time.Time
+}
+
+func New(store db.Store, interval time.Duration, limits db.TransferLimitsByCurrency) *Scheduler {
+	return &Scheduler{
+		store:    store,
+		interval: interval,
+		limits:   limits,
+		now:      time.Now,
+	}
+}
+
+// Run polls for due standing orders every interval until the context is done
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(scheduler.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := scheduler.RunDue(ctx); err != nil {
+				log.Printf("scheduler: %v", err)
+			}
+		}
+	}
+}
+
+// RunDue claims the standing orders that are due and runs them, it returns how many ran
+func (scheduler *Scheduler) RunDue(ctx context.Context) (int, error) {
+	claimed, err := scheduler.store.ClaimDueScheduledTransfers(ctx, db.ClaimDueScheduledTransfersParams{
+		LockedUntil: sql.NullTime{Time: scheduler.now().Add(leaseDuration), Valid: true},
+		BatchSize:   batchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot claim scheduled transfers: %w", err)
+	}
+
+	for i, scheduled := range claimed {
+		if err := scheduler.runOne(ctx, scheduled); err != nil {
+			return i, err
+		}
+	}
+	return len(claimed), nil
+}
+
+func (scheduler *Scheduler) runOne(ctx context.Context, scheduled db.ScheduledTransfer) error {
+	runAt := scheduled.NextRunAt.Time
+
+	//* a failed run (say, not enough money) is recorded and skipped, the order keeps going
+	lastError := ""
+	err := scheduler.transfer(ctx, scheduled, runAt)
+	if err != nil && !errors.Is(err, db.ErrIdempotencyKeyExists) {
+		lastError = err.Error()
+	}
+
+	next, err := NextRun(scheduled, scheduler.now())
+	if err != nil {
+		lastError = err.Error()
+	}
+
+	_, err = scheduler.store.FinishScheduledTransferRun(ctx, db.FinishScheduledTransferRunParams{
+		ID:        scheduled.ID,
+		NextRunAt: next,
+		LastError: lastError,
+	})
+	//* deleted while it ran, nothing left to update
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot finish scheduled transfer [%d]: %w", scheduled.ID, err)
+	}
+	return nil
+}
+
+func (scheduler *Scheduler) transfer(ctx context.Context, scheduled db.ScheduledTransfer, runAt time.Time) error {
+	owner, err := scheduler.store.GetUser(ctx, scheduled.Owner)
+	if err != nil {
+		return err
+	}
+	if owner.DeactivatedAt.Valid {
+		return fmt.Errorf("user %s is deactivated", owner.Username)
+	}
+
+	for _, accountID := range []int64{scheduled.FromAccountID, scheduled.ToAccountID} {
+		account, err := scheduler.store.GetAccount(ctx, accountID)
+		if err != nil {
+			return err
+		}
+		if account.IsFrozen {
+			return fmt.Errorf("account [%d] is frozen", account.ID)
+		}
+
+		//* accounts of deactivated users are read-only, the owner was checked above
+		if account.Owner == owner.Username {
+			continue
+		}
+		accountOwner, err := scheduler.store.GetUser(ctx, account.Owner)
+		if err != nil {
+			return err
+		}
+		if accountOwner.DeactivatedAt.Valid {
+			return fmt.Errorf("account [%d] belongs to a deactivated user", account.ID)
+		}
+	}
+
+	_, err = scheduler.store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: scheduled.FromAccountID,
+		ToAccountID:   scheduled.ToAccountID,
+		Amount:        scheduled.Amount,
+		Idempotency: &db.TransferIdempotency{
+			Owner:          scheduled.Owner,
+			Key:            fmt.Sprintf("%s%d:%d", IdempotencyKeyPrefix, scheduled.ID, runAt.Unix()),
+			RequestHash:    fmt.Sprintf("%d:%d:%d", scheduled.FromAccountID, scheduled.ToAccountID, scheduled.Amount),
+			ResponseStatus: http.StatusOK,
+		},
+		DefaultLimits: scheduler.limits,
+	})
+	return err
+}
+
+// NextRun is the first run after now, runs missed while no scheduler was up are skipped.
+// It is null once the schedule has passed its end date.
+func NextRun(scheduled db.ScheduledTransfer, now time.Time) (sql.NullTime, error) {
+	schedule, err := util.ParseSchedule(scheduled.Schedule, scheduled.StartsAt)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+
+	after := now
+	if now.Before(scheduled.StartsAt) {
+		//* the start itself may be the first run
+		after = scheduled.StartsAt.Add(-time.Nanosecond)
+	}
+
+	next := schedule.Next(after)
+	if next.IsZero() || (scheduled.EndsAt.Valid && next.After(scheduled.EndsAt.Time)) {
+		return sql.NullTime{}, nil
+	}
+	return sql.NullTime{Time: next, Valid: true}, nil
+}
@@ -0,0 +1,277 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	mockdb "github.com/itsadijmbt/simple_bank/db/mock"
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func randomScheduledTransfer(now time.Time) db.ScheduledTransfer {
+	return db.ScheduledTransfer{
+		ID:            util.RandomInt(1, 1000),
+		Owner:         util.RandomOwner(),
+		FromAccountID: util.RandomInt(1, 1000),
+		ToAccountID:   util.RandomInt(1001, 2000),
+		Amount:        util.RandomInt(1, 100),
+		Schedule:      "@every 24h",
+		StartsAt:      now.Add(-time.Hour),
+		NextRunAt:     sql.NullTime{Time: now.Add(-time.Hour), Valid: true},
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	scheduled := randomScheduledTransfer(now)
+	nextRun := sql.NullTime{Time: now.Add(23 * time.Hour), Valid: true}
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Eq(db.TransferTxParams{
+						FromAccountID: scheduled.FromAccountID,
+						ToAccountID:   scheduled.ToAccountID,
+						Amount:        scheduled.Amount,
+						Idempotency: &db.TransferIdempotency{
+							Owner:          scheduled.Owner,
+							Key:            fmt.Sprintf("scheduled_transfer:%d:%d", scheduled.ID, scheduled.NextRunAt.Time.Unix()),
+							RequestHash:    fmt.Sprintf("%d:%d:%d", scheduled.FromAccountID, scheduled.ToAccountID, scheduled.Amount),
+							ResponseStatus: http.StatusOK,
+						},
+					})).
+					Times(1).
+					Return(db.TransferTxResult{}, nil)
+				store.EXPECT().
+					FinishScheduledTransferRun(gomock.Any(), gomock.Eq(db.FinishScheduledTransferRunParams{
+						ID:        scheduled.ID,
+						NextRunAt: nextRun,
+					})).
+					Times(1)
+			},
+		},
+		{
+			//* the transfer committed but the last run crashed before it could be finished
+			name: "AlreadyRan",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, db.ErrIdempotencyKeyExists)
+				store.EXPECT().
+					FinishScheduledTransferRun(gomock.Any(), gomock.Eq(db.FinishScheduledTransferRunParams{
+						ID:        scheduled.ID,
+						NextRunAt: nextRun,
+					})).
+					Times(1)
+			},
+		},
+		{
+			name: "InsufficientFunds",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{}, db.ErrInsufficientFunds)
+				store.EXPECT().
+					FinishScheduledTransferRun(gomock.Any(), gomock.Eq(db.FinishScheduledTransferRunParams{
+						ID:        scheduled.ID,
+						NextRunAt: nextRun,
+						LastError: db.ErrInsufficientFunds.Error(),
+					})).
+					Times(1)
+			},
+		},
+		{
+			name: "DeletedWhileRunning",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					FinishScheduledTransferRun(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ScheduledTransfer{}, sql.ErrNoRows)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				ClaimDueScheduledTransfers(gomock.Any(), gomock.Eq(db.ClaimDueScheduledTransfersParams{
+					LockedUntil: sql.NullTime{Time: now.Add(leaseDuration), Valid: true},
+					BatchSize:   batchSize,
+				})).
+				Times(1).
+				Return([]db.ScheduledTransfer{scheduled}, nil)
+			store.EXPECT().
+				GetUser(gomock.Any(), gomock.Eq(scheduled.Owner)).
+				Times(1).
+				Return(db.User{Username: scheduled.Owner}, nil)
+			store.EXPECT().
+				GetAccount(gomock.Any(), gomock.Any()).
+				Times(2).
+				DoAndReturn(func(_ context.Context, id int64) (db.Account, error) {
+					return db.Account{ID: id, Owner: scheduled.Owner}, nil
+				})
+			tc.buildStubs(store)
+
+			scheduler := New(store, time.Minute, nil)
+			scheduler.now = func() time.Time { return now }
+
+			n, err := scheduler.RunDue(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+		})
+	}
+}
+
+func TestRunDueFrozenAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	scheduled := randomScheduledTransfer(now)
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ClaimDueScheduledTransfers(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.ScheduledTransfer{scheduled}, nil)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.User{Username: scheduled.Owner}, nil)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(scheduled.FromAccountID)).
+		Times(1).
+		Return(db.Account{ID: scheduled.FromAccountID, IsFrozen: true}, nil)
+	store.EXPECT().
+		TransferTx(gomock.Any(), gomock.Any()).
+		Times(0)
+	store.EXPECT().
+		FinishScheduledTransferRun(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.FinishScheduledTransferRunParams) (db.ScheduledTransfer, error) {
+			require.Contains(t, arg.LastError, "frozen")
+			require.True(t, arg.NextRunAt.Valid)
+			return db.ScheduledTransfer{}, nil
+		})
+
+	n, err := New(store, time.Minute, nil).RunDue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestRunDueDeactivatedRecipient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	scheduled := randomScheduledTransfer(now)
+	recipient := util.RandomOwner()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ClaimDueScheduledTransfers(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.ScheduledTransfer{scheduled}, nil)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(scheduled.Owner)).
+		Times(1).
+		Return(db.User{Username: scheduled.Owner}, nil)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(scheduled.FromAccountID)).
+		Times(1).
+		Return(db.Account{ID: scheduled.FromAccountID, Owner: scheduled.Owner}, nil)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(scheduled.ToAccountID)).
+		Times(1).
+		Return(db.Account{ID: scheduled.ToAccountID, Owner: recipient}, nil)
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(recipient)).
+		Times(1).
+		Return(db.User{Username: recipient, DeactivatedAt: sql.NullTime{Time: now, Valid: true}}, nil)
+	store.EXPECT().
+		TransferTx(gomock.Any(), gomock.Any()).
+		Times(0)
+	store.EXPECT().
+		FinishScheduledTransferRun(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.FinishScheduledTransferRunParams) (db.ScheduledTransfer, error) {
+			require.Contains(t, arg.LastError, "deactivated")
+			require.True(t, arg.NextRunAt.Valid)
+			return db.ScheduledTransfer{}, nil
+		})
+
+	n, err := New(store, time.Minute, nil).RunDue(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		scheduled db.ScheduledTransfer
+		want      sql.NullTime
+	}{
+		{
+			name:      "StartsLater",
+			scheduled: db.ScheduledTransfer{Schedule: "@every 24h", StartsAt: now.Add(time.Hour)},
+			want:      sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+		},
+		{
+			name:      "SkipsMissedRuns",
+			scheduled: db.ScheduledTransfer{Schedule: "@every 24h", StartsAt: now.Add(-100 * time.Hour)},
+			want:      sql.NullTime{Time: now.Add(20 * time.Hour), Valid: true},
+		},
+		{
+			name:      "Cron",
+			scheduled: db.ScheduledTransfer{Schedule: "0 9 1 * *", StartsAt: now},
+			want:      sql.NullTime{Time: time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC), Valid: true},
+		},
+		{
+			name: "Ended",
+			scheduled: db.ScheduledTransfer{
+				Schedule: "@every 24h",
+				StartsAt: now.Add(-time.Hour),
+				EndsAt:   sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+			},
+			want: sql.NullTime{},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			next, err := NextRun(tc.scheduled, now)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, next)
+		})
+	}
+
+	_, err := NextRun(db.ScheduledTransfer{Schedule: "whenever"}, now)
+	require.Error(t, err)
+}
@@ -0,0 +1,116 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ^ CachedRevocationStore sits in front of another RevocationStore (normally postgres)
+// ^ so the auth middleware does not hit the db on every request.
+// ^ revoked ids are remembered until the token expires, "not revoked" answers only for ttl.
+// ^ with a nil next store it works as a purely in-memory store (single instance / tests)
+type CachedRevocationStore struct {
+	next RevocationStore
+	ttl  time.Duration
+
+	mu sync.RWMutex
+	//* token id -> token expiry
+	revoked map[uuid.UUID]time.Time
+	//* username -> tokens issued before this are revoked
+	revokedBefore map[string]time.Time
+	//* token id -> when the "not revoked" answer stops being trusted
+	checked map[uuid.UUID]time.Time
+}
+
+func NewCachedRevocationStore(next RevocationStore, ttl time.Duration) *CachedRevocationStore {
+	return &CachedRevocationStore{
+		next:          next,
+		ttl:           ttl,
+		revoked:       make(map[uuid.UUID]time.Time),
+		revokedBefore: make(map[string]time.Time),
+		checked:       make(map[uuid.UUID]time.Time),
+	}
+}
+
+func (store *CachedRevocationStore) Revoke(ctx context.Context, payload *Payload) error {
+	if store.next != nil {
+		if err := store.next.Revoke(ctx, payload); err != nil {
+			return err
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.prune(time.Now())
+	store.revoked[payload.Id] = payload.ExpiredAt
+	delete(store.checked, payload.Id)
+	return nil
+}
+
+func (store *CachedRevocationStore) RevokeAll(ctx context.Context, username string, before time.Time) error {
+	if store.next != nil {
+		if err := store.next.RevokeAll(ctx, username, before); err != nil {
+			return err
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.revokedBefore[username] = before
+	//! cached "not revoked" answers may now be wrong
+	store.checked = make(map[uuid.UUID]time.Time)
+	return nil
+}
+
+func (store *CachedRevocationStore) IsRevoked(ctx context.Context, payload *Payload) (bool, error) {
+	now := time.Now()
+
+	store.mu.RLock()
+	_, revoked := store.revoked[payload.Id]
+	before, hasCutoff := store.revokedBefore[payload.Username]
+	checkedUntil, checked := store.checked[payload.Id]
+	store.mu.RUnlock()
+
+	if revoked || (hasCutoff && payload.IssuedAt.Before(before)) {
+		return true, nil
+	}
+
+	if store.next == nil || (checked && now.Before(checkedUntil)) {
+		return false, nil
+	}
+
+	revoked, err := store.next.IsRevoked(ctx, payload)
+	if err != nil {
+		return false, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.prune(now)
+	if revoked {
+		store.revoked[payload.Id] = payload.ExpiredAt
+	} else if store.ttl > 0 {
+		store.checked[payload.Id] = now.Add(store.ttl)
+	}
+	return revoked, nil
+}
+
+// * drops entries for tokens that have expired anyway, caller must hold the lock
+func (store *CachedRevocationStore) prune(now time.Time) {
+	for id, expiredAt := range store.revoked {
+		if now.After(expiredAt) {
+			delete(store.revoked, id)
+		}
+	}
+	for id, checkedUntil := range store.checked {
+		if now.After(checkedUntil) {
+			delete(store.checked, id)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+// ^ counts lookups so we can see when the cache answers on its own
+type countingRevocationStore struct {
+	RevocationStore
+	lookups int
+}
+
+func (store *countingRevocationStore) IsRevoked(ctx context.Context, payload *Payload) (bool, error) {
+	store.lookups++
+	return store.RevocationStore.IsRevoked(ctx, payload)
+}
+
+func TestCachedRevocationStore(t *testing.T) {
+	store := NewCachedRevocationStore(nil, 0)
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	revoked, err := store.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	require.NoError(t, store.Revoke(context.Background(), payload))
+
+	revoked, err = store.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestCachedRevocationStoreRevokeAll(t *testing.T) {
+	store := NewCachedRevocationStore(nil, 0)
+
+	username := util.RandomOwner()
+	oldPayload, err := NewPayload(username, util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, store.RevokeAll(context.Background(), username, time.Now()))
+
+	newPayload, err := NewPayload(username, util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	revoked, err := store.IsRevoked(context.Background(), oldPayload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = store.IsRevoked(context.Background(), newPayload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestCachedRevocationStoreCachesLookups(t *testing.T) {
+	//* a cache that wraps another cache stands in for the postgres store here
+	next := &countingRevocationStore{RevocationStore: NewCachedRevocationStore(nil, 0)}
+	store := NewCachedRevocationStore(next, time.Minute)
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		revoked, err := store.IsRevoked(context.Background(), payload)
+		require.NoError(t, err)
+		require.False(t, revoked)
+	}
+	require.Equal(t, 1, next.lookups)
+
+	//* a revocation made through the cache is seen immediately
+	require.NoError(t, store.Revoke(context.Background(), payload))
+	revoked, err := store.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
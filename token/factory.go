@@ -0,0 +1,144 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+)
+
+// ^ values accepted by TOKEN_TYPE in app.env
+const (
+	TypePasetoLocal  = "paseto_v2_local"
+	TypePasetoPublic = "paseto_v4_public"
+	TypeJWTHS256     = "jwt_hs256"
+	TypeJWTEdDSA     = "jwt_eddsa"
+	TypeJWTRS256     = "jwt_rs256"
+)
+
+// NewMakerFromConfig builds the token maker selected by TOKEN_TYPE.
+// the symmetric types use TOKEN_SYMMETRIC_KEY, the public-key types use
+// TOKEN_PRIVATE_KEY, or only TOKEN_PUBLIC_KEY for a verify-only maker.
+//
+// with TOKEN_KEY_ID set the maker becomes a keyring: new tokens carry that key id
+// and TOKEN_VERIFICATION_KEYS ("kid:key,kid:key") lists retired keys that still verify
+// (symmetric keys for the symmetric types, public keys for the others)
+func NewMakerFromConfig(config util.Config) (Maker, error) {
+
+	active, err := newActiveMaker(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TokenKeyID == "" {
+		if config.TokenVerificationKeys != "" {
+			return nil, fmt.Errorf("TOKEN_VERIFICATION_KEYS needs a TOKEN_KEY_ID for the active key")
+		}
+		return active, nil
+	}
+
+	makers := map[string]Maker{config.TokenKeyID: active}
+
+	for _, entry := range strings.Split(config.TokenVerificationKeys, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		keyID, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || keyID == "" {
+			return nil, fmt.Errorf("invalid verification key %q, expected kid:key", entry)
+		}
+		if _, exists := makers[keyID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", keyID)
+		}
+
+		makers[keyID], err = newVerificationMaker(config.TokenType, key)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %q: %w", keyID, err)
+		}
+	}
+
+	return NewKeyringMaker(config.TokenKeyID, makers)
+}
+
+func newActiveMaker(config util.Config) (Maker, error) {
+
+	switch config.TokenType {
+	case "", TypePasetoLocal:
+		//* empty keeps the old behaviour for configs without TOKEN_TYPE
+		return NewPasetoMaker(config.TokenSymmetricKey)
+
+	case TypeJWTHS256:
+		return NewJWTMaker(config.TokenSymmetricKey)
+
+	case TypePasetoPublic, TypeJWTEdDSA, TypeJWTRS256:
+		if config.TokenPrivateKey == "" {
+			return newVerificationMaker(config.TokenType, config.TokenPublicKey)
+		}
+
+		privateKey, err := ParsePrivateKey(config.TokenPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.TokenType == TypePasetoPublic {
+			edKey, ok := privateKey.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("%s needs an ed25519 key", config.TokenType)
+			}
+			return NewPasetoPublicMaker(edKey)
+		}
+
+		maker, err := NewAsymmetricJWTMaker(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		return maker, checkJWTAlgorithm(config.TokenType, maker)
+	}
+
+	return nil, fmt.Errorf("unsupported token type %q", config.TokenType)
+}
+
+// * a maker that can only check tokens signed with the given key
+func newVerificationMaker(tokenType string, key string) (Maker, error) {
+
+	switch tokenType {
+	case "", TypePasetoLocal:
+		return NewPasetoMaker(key)
+
+	case TypeJWTHS256:
+		return NewJWTMaker(key)
+
+	case TypePasetoPublic, TypeJWTEdDSA, TypeJWTRS256:
+		publicKey, err := ParsePublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if tokenType == TypePasetoPublic {
+			edKey, ok := publicKey.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("%s needs an ed25519 key", tokenType)
+			}
+			return NewPasetoPublicVerifier(edKey)
+		}
+
+		maker, err := NewAsymmetricJWTVerifier(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		return maker, checkJWTAlgorithm(tokenType, maker)
+	}
+
+	return nil, fmt.Errorf("unsupported token type %q", tokenType)
+}
+
+// ! the key decides the jwt algorithm, make sure it is the one that was asked for
+func checkJWTAlgorithm(tokenType string, maker Maker) error {
+	want := map[string]string{TypeJWTEdDSA: "EdDSA", TypeJWTRS256: "RS256"}[tokenType]
+	if got := maker.(*AsymmetricJWTMaker).method.Alg(); got != want {
+		return fmt.Errorf("%s does not match a key for %s", tokenType, got)
+	}
+	return nil
+}
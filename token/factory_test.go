@@ -0,0 +1,83 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMakerFromConfig(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	//* PEM as it would be written into app.env with escaped newlines
+	privatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	privateEnv := strings.ReplaceAll(privatePEM, "\n", `\n`)
+	publicB64 := base64.StdEncoding.EncodeToString(publicDER)
+
+	for _, tokenType := range []string{TypePasetoPublic, TypeJWTEdDSA} {
+		t.Run(tokenType, func(t *testing.T) {
+			maker, err := NewMakerFromConfig(util.Config{TokenType: tokenType, TokenPrivateKey: privateEnv})
+			require.NoError(t, err)
+
+			verifier, err := NewMakerFromConfig(util.Config{TokenType: tokenType, TokenPublicKey: publicB64})
+			require.NoError(t, err)
+
+			token, _, err := maker.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, time.Minute)
+			require.NoError(t, err)
+
+			_, err = verifier.VerifyToken(token)
+			require.NoError(t, err)
+		})
+	}
+
+	_, err = NewMakerFromConfig(util.Config{TokenType: TypeJWTRS256, TokenPrivateKey: privateEnv})
+	require.Error(t, err)
+
+	_, err = NewMakerFromConfig(util.Config{TokenType: "unknown"})
+	require.Error(t, err)
+
+	maker, err := NewMakerFromConfig(util.Config{TokenSymmetricKey: util.RandomString(32)})
+	require.NoError(t, err)
+	require.IsType(t, &PasetoMaker{}, maker)
+}
+
+func TestNewMakerFromConfigKeyring(t *testing.T) {
+	oldKey := util.RandomString(32)
+	newKey := util.RandomString(32)
+
+	oldMaker, err := NewMakerFromConfig(util.Config{TokenSymmetricKey: oldKey, TokenKeyID: "k1"})
+	require.NoError(t, err)
+	oldToken, _, err := oldMaker.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	maker, err := NewMakerFromConfig(util.Config{
+		TokenSymmetricKey:     newKey,
+		TokenKeyID:            "k2",
+		TokenVerificationKeys: "k1:" + oldKey,
+	})
+	require.NoError(t, err)
+	require.IsType(t, &KeyringMaker{}, maker)
+
+	_, err = maker.VerifyToken(oldToken)
+	require.NoError(t, err)
+
+	_, err = NewMakerFromConfig(util.Config{TokenSymmetricKey: newKey, TokenVerificationKeys: "k1:" + oldKey})
+	require.Error(t, err)
+
+	_, err = NewMakerFromConfig(util.Config{TokenSymmetricKey: newKey, TokenKeyID: "k2", TokenVerificationKeys: oldKey})
+	require.Error(t, err)
+}
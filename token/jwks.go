@@ -0,0 +1,89 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// ^ JWK is a single public key as published at /.well-known/jwks.json (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg,omitempty"`
+	//* OKP (Ed25519) keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	//* RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ^ implemented by makers whose verification key is public
+type JWKSProvider interface {
+	JWKS() JWKSet
+}
+
+// ^ implemented by the asymmetric makers, symmetric keys are never published
+type publicKeyMaker interface {
+	publicJWK(keyID string) JWK
+}
+
+func ed25519JWK(keyID string, algorithm string, publicKey ed25519.PublicKey) JWK {
+	return JWK{
+		KeyType:   "OKP",
+		KeyID:     keyID,
+		Use:       "sig",
+		Algorithm: algorithm,
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(publicKey),
+	}
+}
+
+func rsaJWK(keyID string, algorithm string, publicKey *rsa.PublicKey) JWK {
+	return JWK{
+		KeyType:   "RSA",
+		KeyID:     keyID,
+		Use:       "sig",
+		Algorithm: algorithm,
+		N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+	}
+}
+
+// * only keys that can be published end up in the set
+func (ring *KeyringMaker) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, keyID := range ring.KeyIDs() {
+		if maker, ok := ring.makers[keyID].(publicKeyMaker); ok {
+			set.Keys = append(set.Keys, maker.publicJWK(keyID))
+		}
+	}
+	return set
+}
+
+func (maker *PasetoPublicMaker) JWKS() JWKSet {
+	return JWKSet{Keys: []JWK{maker.publicJWK("")}}
+}
+
+func (maker *AsymmetricJWTMaker) JWKS() JWKSet {
+	return JWKSet{Keys: []JWK{maker.publicJWK("")}}
+}
+
+// * PASETO has no JOSE algorithm name, the key itself is still an Ed25519 OKP
+func (maker *PasetoPublicMaker) publicJWK(keyID string) JWK {
+	return ed25519JWK(keyID, "", maker.publicKey)
+}
+
+func (maker *AsymmetricJWTMaker) publicJWK(keyID string) JWK {
+	if key, ok := maker.publicKey.(*rsa.PublicKey); ok {
+		return rsaJWK(keyID, maker.method.Alg(), key)
+	}
+	return ed25519JWK(keyID, maker.method.Alg(), maker.publicKey.(ed25519.PublicKey))
+}
@@ -0,0 +1,116 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// ^ minimum RSA modulus we accept for RS256
+const minRSAKeyBits = 2048
+
+// ^ AsymmetricJWTMaker signs with EdDSA (Ed25519 keys) or RS256 (RSA keys),
+// ^ the algorithm follows the key type so the two can never be mixed up
+type AsymmetricJWTMaker struct {
+	method jwt.SigningMethod
+	//* nil when the maker is verify only
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+}
+
+func NewAsymmetricJWTMaker(privateKey crypto.Signer) (Maker, error) {
+
+	method, err := jwtMethodForKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	maker := &AsymmetricJWTMaker{
+		method:     method,
+		privateKey: privateKey,
+		publicKey:  privateKey.Public(),
+	}
+	return maker, nil
+}
+
+// ! for services that only check our tokens and never issue them
+func NewAsymmetricJWTVerifier(publicKey crypto.PublicKey) (Maker, error) {
+
+	method, err := jwtMethodForKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AsymmetricJWTMaker{method: method, publicKey: publicKey}, nil
+}
+
+func jwtMethodForKey(publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", minRSAKeyBits)
+		}
+		return jwt.SigningMethodRS256, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", publicKey)
+}
+
+func (maker *AsymmetricJWTMaker) CreateToken(username string, role string, tokenType string, duration time.Duration) (string, *Payload, error) {
+	return createToken(maker, "", username, role, tokenType, duration)
+}
+
+func (maker *AsymmetricJWTMaker) SignPayload(payload *Payload) (string, error) {
+	return maker.signPayload("", payload)
+}
+
+func (maker *AsymmetricJWTMaker) signPayload(keyID string, payload *Payload) (string, error) {
+
+	if maker.privateKey == nil {
+		return "", ErrVerifyOnly
+	}
+
+	jwtToken := jwt.NewWithClaims(maker.method, payload)
+	if keyID != "" {
+		jwtToken.Header["kid"] = keyID
+	}
+	return jwtToken.SignedString(maker.privateKey)
+}
+
+func (maker *AsymmetricJWTMaker) tokenKeyID(token string) (string, error) {
+	return jwtHeaderKeyID(token)
+}
+
+func (maker *AsymmetricJWTMaker) VerifyToken(token string) (*Payload, error) {
+
+	//! the alg header must be exactly our method, otherwise a HS256 token
+	//! "signed" with the public key bytes could slip through
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != maker.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return maker.publicKey, nil
+	}
+
+	jwtToken, err := jwt.ParseWithClaims(token, &Payload{}, keyfunc)
+	if err != nil {
+		verr, ok := err.(*jwt.ValidationError)
+		if ok && errors.Is(verr.Inner, ErrExpiredToken) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	payload, ok := jwtToken.Claims.(*Payload)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
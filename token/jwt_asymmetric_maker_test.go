@@ -0,0 +1,87 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsymmetricJWTMaker(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys := map[string]crypto.Signer{"EdDSA": edKey, "RS256": rsaKey}
+
+	for alg, privateKey := range keys {
+		t.Run(alg, func(t *testing.T) {
+			maker, err := NewAsymmetricJWTMaker(privateKey)
+			require.NoError(t, err)
+
+			username := util.RandomOwner()
+			token, _, err := maker.CreateToken(username, util.DepositorRole, TokenTypeAccess, time.Minute)
+			require.NoError(t, err)
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(token, &Payload{})
+			require.NoError(t, err)
+			require.Equal(t, alg, parsed.Method.Alg())
+
+			//* verification only needs the public key
+			verifier, err := NewAsymmetricJWTVerifier(privateKey.Public())
+			require.NoError(t, err)
+
+			payload, err := verifier.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, username, payload.Username)
+			require.Equal(t, util.DepositorRole, payload.Role)
+
+			_, _, err = verifier.CreateToken(username, util.DepositorRole, TokenTypeAccess, time.Minute)
+			require.ErrorIs(t, err, ErrVerifyOnly)
+
+			expired, _, err := maker.CreateToken(username, util.DepositorRole, TokenTypeAccess, -time.Minute)
+			require.NoError(t, err)
+			_, err = verifier.VerifyToken(expired)
+			require.EqualError(t, err, ErrExpiredToken.Error())
+		})
+	}
+}
+
+// ^ classic algorithm confusion: HS256 "signed" with the public key bytes
+func TestAsymmetricJWTRejectsHMAC(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	maker, err := NewAsymmetricJWTMaker(rsaKey)
+	require.NoError(t, err)
+
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	require.NoError(t, err)
+
+	payload, err := NewPayload(util.RandomOwner(), util.BankerRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, payload).SignedString(publicDER)
+	require.NoError(t, err)
+
+	payload, err = maker.VerifyToken(forged)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestAsymmetricJWTRejectsSmallRSAKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	_, err = NewAsymmetricJWTMaker(rsaKey)
+	require.Error(t, err)
+}
@@ -27,15 +27,20 @@ func NewJWTMaker(secretKey string) (Maker, error) {
 	return &JWTMaker{secretKey}, nil
 }
 
-func (maker *JWTMaker) CreateToken(username string, duration time.Duration) (string, error) {
+func (maker *JWTMaker) CreateToken(username string, role string, tokenType string, duration time.Duration) (string, *Payload, error) {
+	return createToken(maker, "", username, role, tokenType, duration)
+}
 
-	payload, err := NewPayload(username, duration)
+func (maker *JWTMaker) SignPayload(payload *Payload) (string, error) {
+	return maker.signPayload("", payload)
+}
 
-	if err != nil {
-		return "", err
-	}
+func (maker *JWTMaker) signPayload(keyID string, payload *Payload) (string, error) {
 
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	if keyID != "" {
+		jwtToken.Header["kid"] = keyID
+	}
 	return jwtToken.SignedString([]byte(maker.secretKey))
 
 }
@@ -92,6 +97,20 @@ func (maker *JWTMaker) VerifyToken(token string) (*Payload, error) {
 	return payload, nil
 }
 
+func (maker *JWTMaker) tokenKeyID(token string) (string, error) {
+	return jwtHeaderKeyID(token)
+}
+
+// * the header is read unverified, it only picks which key to verify with
+func jwtHeaderKeyID(token string) (string, error) {
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &Payload{})
+	if err != nil {
+		return "", err
+	}
+	keyID, _ := parsed.Header["kid"].(string)
+	return keyID, nil
+}
+
 //level diagram for refernce
 /*
 ParseWithClaims(tokenString, &Payload{}, keyfunc)
@@ -0,0 +1,92 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ^ keyedMaker is implemented by every maker in this package, it lets the
+// ^ keyring put a key id into the token (footer or header) and read it back
+type keyedMaker interface {
+	Maker
+	payloadSigner
+	//* reads the key id without verifying anything, "" for tokens without one
+	tokenKeyID(token string) (string, error)
+}
+
+// ^ paseto footers carry the key id as json, jwt uses the "kid" header instead
+type keyIDFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// ^ KeyringMaker signs with one active key and still verifies tokens
+// ^ signed by older keys, so rotating the key does not log everyone out
+type KeyringMaker struct {
+	activeKeyID string
+	makers      map[string]keyedMaker
+}
+
+// NewKeyringMaker takes one maker per key id, the active one is used for new tokens.
+// retired keys only need to verify so they can be verify-only makers
+func NewKeyringMaker(activeKeyID string, makers map[string]Maker) (*KeyringMaker, error) {
+
+	ring := &KeyringMaker{
+		activeKeyID: activeKeyID,
+		makers:      make(map[string]keyedMaker, len(makers)),
+	}
+
+	for keyID, maker := range makers {
+		keyed, ok := maker.(keyedMaker)
+		if !ok {
+			return nil, fmt.Errorf("maker for key %q does not support key ids", keyID)
+		}
+		ring.makers[keyID] = keyed
+	}
+
+	if _, ok := ring.makers[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", activeKeyID)
+	}
+	return ring, nil
+}
+
+func (ring *KeyringMaker) ActiveKeyID() string {
+	return ring.activeKeyID
+}
+
+// * sorted so the jwks output is stable
+func (ring *KeyringMaker) KeyIDs() []string {
+	ids := make([]string, 0, len(ring.makers))
+	for keyID := range ring.makers {
+		ids = append(ids, keyID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (ring *KeyringMaker) CreateToken(username string, role string, tokenType string, duration time.Duration) (string, *Payload, error) {
+	return createToken(ring.makers[ring.activeKeyID], ring.activeKeyID, username, role, tokenType, duration)
+}
+
+func (ring *KeyringMaker) SignPayload(payload *Payload) (string, error) {
+	return ring.makers[ring.activeKeyID].signPayload(ring.activeKeyID, payload)
+}
+
+func (ring *KeyringMaker) VerifyToken(token string) (*Payload, error) {
+
+	keyID, err := ring.makers[ring.activeKeyID].tokenKeyID(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	maker, ok := ring.makers[keyID]
+	if !ok {
+		//* tokens from before key ids were introduced have none
+		if keyID != "" {
+			return nil, ErrInvalidToken
+		}
+		maker = ring.makers[ring.activeKeyID]
+	}
+
+	return maker.VerifyToken(token)
+}
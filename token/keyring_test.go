@@ -0,0 +1,100 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyringMakerRotation(t *testing.T) {
+	oldMaker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+	newMaker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	username := util.RandomOwner()
+
+	//* issued before key ids existed, by the key that is now being retired
+	legacy, _, err := oldMaker.CreateToken(username, util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	before, err := NewKeyringMaker("k1", map[string]Maker{"k1": oldMaker})
+	require.NoError(t, err)
+	oldToken, _, err := before.CreateToken(username, util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	//* rotate: k2 signs, k1 still verifies
+	ring, err := NewKeyringMaker("k2", map[string]Maker{"k1": oldMaker, "k2": newMaker})
+	require.NoError(t, err)
+	require.Equal(t, "k2", ring.ActiveKeyID())
+	require.Equal(t, []string{"k1", "k2"}, ring.KeyIDs())
+
+	newToken, _, err := ring.CreateToken(username, util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	for _, token := range []string{oldToken, newToken} {
+		payload, err := ring.VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, username, payload.Username)
+	}
+
+	kid, err := newMaker.(*PasetoMaker).tokenKeyID(newToken)
+	require.NoError(t, err)
+	require.Equal(t, "k2", kid)
+
+	//* no key id falls back to the active key, which did not sign it
+	_, err = ring.VerifyToken(legacy)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	legacyRing, err := NewKeyringMaker("k1", map[string]Maker{"k1": oldMaker})
+	require.NoError(t, err)
+	_, err = legacyRing.VerifyToken(legacy)
+	require.NoError(t, err)
+
+	//* once k1 is dropped its tokens stop working
+	after, err := NewKeyringMaker("k2", map[string]Maker{"k2": newMaker})
+	require.NoError(t, err)
+	_, err = after.VerifyToken(oldToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	_, err = NewKeyringMaker("missing", map[string]Maker{"k1": oldMaker})
+	require.Error(t, err)
+}
+
+func TestKeyringMakerJWKS(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	oldPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	active, err := NewAsymmetricJWTMaker(privateKey)
+	require.NoError(t, err)
+	retired, err := NewAsymmetricJWTVerifier(oldPublic)
+	require.NoError(t, err)
+
+	ring, err := NewKeyringMaker("2024", map[string]Maker{"2024": active, "2023": retired})
+	require.NoError(t, err)
+
+	token, _, err := ring.CreateToken(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+	kid, err := jwtHeaderKeyID(token)
+	require.NoError(t, err)
+	require.Equal(t, "2024", kid)
+
+	set := ring.JWKS()
+	require.Len(t, set.Keys, 2)
+	require.Equal(t, "2023", set.Keys[0].KeyID)
+	require.Equal(t, "2024", set.Keys[1].KeyID)
+	require.Equal(t, ed25519JWK("2024", "EdDSA", publicKey), set.Keys[1])
+
+	//* symmetric keys are never published
+	symmetric, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+	symmetricRing, err := NewKeyringMaker("k1", map[string]Maker{"k1": symmetric})
+	require.NoError(t, err)
+	require.Empty(t, symmetricRing.JWKS().Keys)
+}
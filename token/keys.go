@@ -0,0 +1,74 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ^ returned by makers built from a public key only, they can verify but not sign
+var ErrVerifyOnly = errors.New("token maker holds no private key and can only verify tokens")
+
+// * keys come from env vars, so both PEM (newlines may be escaped as \n)
+// * and plain base64 of the DER bytes are accepted
+func decodeKey(key string) ([]byte, error) {
+	key = strings.TrimSpace(strings.ReplaceAll(key, `\n`, "\n"))
+
+	if strings.HasPrefix(key, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(key))
+		if block == nil {
+			return nil, errors.New("invalid PEM key")
+		}
+		return block.Bytes, nil
+	}
+
+	return base64.StdEncoding.DecodeString(key)
+}
+
+// ParsePrivateKey reads a PKCS#8 Ed25519 or RSA private key
+func ParsePrivateKey(key string) (crypto.Signer, error) {
+	der, err := decodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode private key: %w", err)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse private key: %w", err)
+	}
+
+	switch k := parsed.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", parsed)
+}
+
+// ParsePublicKey reads a PKIX Ed25519 or RSA public key
+func ParsePublicKey(key string) (crypto.PublicKey, error) {
+	der, err := decodeKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode public key: %w", err)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse public key: %w", err)
+	}
+
+	switch k := parsed.(type) {
+	case ed25519.PublicKey:
+		return k, nil
+	case *rsa.PublicKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", parsed)
+}
@@ -6,8 +6,30 @@ import "time"
 type Maker interface {
 
 	//*token creation for a specific user and time
-	CreateToken(username string, duration time.Duration) (string, error)
+	//* the payload is returned too so callers can persist its id and expiry (e.g. sessions)
+	//* tokenType is TokenTypeAccess or TokenTypeRefresh
+	CreateToken(username string, role string, tokenType string, duration time.Duration) (string, *Payload, error)
+
+	//* signs a payload built by the caller, for tokens that carry more than
+	//* a username and a role (the scopes and client of an oauth token)
+	SignPayload(payload *Payload) (string, error)
 
 	//* returns the payload inside the token!
 	VerifyToken(token string) (*Payload, error)
 }
+
+// ^ payloadSigner is what every maker in this package implements,
+// ^ CreateToken and the keyring are built on top of it
+type payloadSigner interface {
+	signPayload(keyID string, payload *Payload) (string, error)
+}
+
+func createToken(signer payloadSigner, keyID string, username string, role string, tokenType string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, tokenType, duration)
+	if err != nil {
+		return "", payload, err
+	}
+
+	token, err := signer.signPayload(keyID, payload)
+	return token, payload, err
+}
@@ -30,15 +30,31 @@ func NewPasetoMaker(symmetricKey string) (Maker, error) {
 	return maker, nil
 }
 
-func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, error) {
-	// passing in the username and how long the token should be valid.
-	payload, err := NewPayload(username, duration)
+func (maker *PasetoMaker) CreateToken(username string, role string, tokenType string, duration time.Duration) (string, *Payload, error) {
+	return createToken(maker, "", username, role, tokenType, duration)
+}
 
-	if err != nil {
-		return "", err
+func (maker *PasetoMaker) SignPayload(payload *Payload) (string, error) {
+	return maker.signPayload("", payload)
+}
+
+func (maker *PasetoMaker) signPayload(keyID string, payload *Payload) (string, error) {
+	//* the key id goes into the (authenticated, unencrypted) footer
+	var footer interface{}
+	if keyID != "" {
+		footer = keyIDFooter{KeyID: keyID}
 	}
+
 	//* payload is serialized (converted to JSON) and encrypted with the secret key.
-	return maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+	return maker.paseto.Encrypt(maker.symmetricKey, payload, footer)
+}
+
+func (maker *PasetoMaker) tokenKeyID(token string) (string, error) {
+	var footer keyIDFooter
+	if err := paseto.ParseFooter(token, &footer); err != nil {
+		return "", err
+	}
+	return footer.KeyID, nil
 }
 
 func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
@@ -78,10 +94,10 @@ func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
  Call NewPasetoMaker() once to create and store the token engine with secret key.
 
   When user logs in:
- Call CreateToken(username, duration)
+ Call CreateToken(username, role, tokenType, duration)
  → A new Payload is created
  → Encrypted using Paseto V2 and symmetric key
- → Returns a secure token string
+ → Returns a secure token string along with its payload
 
   When user makes a request with token:
  Call VerifyToken(token)
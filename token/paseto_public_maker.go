@@ -0,0 +1,161 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ^ every v4.public token starts with this header
+const pasetoV4PublicHeader = "v4.public."
+
+// ^ PasetoPublicMaker signs PASETO v4.public tokens with Ed25519.
+// ^ unlike v2.local the payload is readable (signed, not encrypted) and anyone
+// ^ holding only the public key can verify it
+type PasetoPublicMaker struct {
+	//* nil when the maker is verify only
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+func NewPasetoPublicMaker(privateKey ed25519.PrivateKey) (Maker, error) {
+
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid key size, must be %d bytes", ed25519.PrivateKeySize)
+	}
+
+	maker := &PasetoPublicMaker{
+		privateKey: privateKey,
+		publicKey:  privateKey.Public().(ed25519.PublicKey),
+	}
+	return maker, nil
+}
+
+// ! for services that only check our tokens and never issue them
+func NewPasetoPublicVerifier(publicKey ed25519.PublicKey) (Maker, error) {
+
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid key size, must be %d bytes", ed25519.PublicKeySize)
+	}
+
+	return &PasetoPublicMaker{publicKey: publicKey}, nil
+}
+
+func (maker *PasetoPublicMaker) CreateToken(username string, role string, tokenType string, duration time.Duration) (string, *Payload, error) {
+	return createToken(maker, "", username, role, tokenType, duration)
+}
+
+func (maker *PasetoPublicMaker) SignPayload(payload *Payload) (string, error) {
+	return maker.signPayload("", payload)
+}
+
+func (maker *PasetoPublicMaker) signPayload(keyID string, payload *Payload) (string, error) {
+
+	if maker.privateKey == nil {
+		return "", ErrVerifyOnly
+	}
+
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	//* the key id goes into the footer, which is signed too
+	var footer []byte
+	if keyID != "" {
+		if footer, err = json.Marshal(keyIDFooter{KeyID: keyID}); err != nil {
+			return "", err
+		}
+	}
+
+	//* the signature covers header, message and footer (no implicit assertion)
+	signature := ed25519.Sign(maker.privateKey, preAuthEncode([]byte(pasetoV4PublicHeader), message, footer, nil))
+
+	token := pasetoV4PublicHeader + base64.RawURLEncoding.EncodeToString(append(message, signature...))
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+	return token, nil
+}
+
+// * splits "v4.public.<body>[.<footer>]" and decodes both parts
+func splitPasetoPublicToken(token string) (body []byte, footer []byte, err error) {
+
+	if !strings.HasPrefix(token, pasetoV4PublicHeader) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	parts := strings.Split(strings.TrimPrefix(token, pasetoV4PublicHeader), ".")
+	if len(parts) > 2 {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if body, err = base64.RawURLEncoding.DecodeString(parts[0]); err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if len(parts) == 2 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+			return nil, nil, ErrInvalidToken
+		}
+	}
+	return body, footer, nil
+}
+
+func (maker *PasetoPublicMaker) tokenKeyID(token string) (string, error) {
+	_, footer, err := splitPasetoPublicToken(token)
+	if err != nil || len(footer) == 0 {
+		return "", err
+	}
+
+	var parsed keyIDFooter
+	if err := json.Unmarshal(footer, &parsed); err != nil {
+		return "", err
+	}
+	return parsed.KeyID, nil
+}
+
+func (maker *PasetoPublicMaker) VerifyToken(token string) (*Payload, error) {
+
+	raw, footer, err := splitPasetoPublicToken(token)
+	if err != nil || len(raw) < ed25519.SignatureSize {
+		return nil, ErrInvalidToken
+	}
+
+	message := raw[:len(raw)-ed25519.SignatureSize]
+	signature := raw[len(raw)-ed25519.SignatureSize:]
+
+	if !ed25519.Verify(maker.publicKey, preAuthEncode([]byte(pasetoV4PublicHeader), message, footer, nil), signature) {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err := json.Unmarshal(message, payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := payload.Valid(); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// * PAE from the PASETO spec: the piece count and every piece length are
+// * written as little endian uint64 (top bit cleared) so pieces cannot be shifted around
+func preAuthEncode(pieces ...[]byte) []byte {
+	le64 := func(n int) []byte {
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, uint64(n)&^(1<<63))
+		return buf
+	}
+
+	out := le64(len(pieces))
+	for _, piece := range pieces {
+		out = append(out, le64(len(piece))...)
+		out = append(out, piece...)
+	}
+	return out
+}
@@ -10,15 +10,29 @@ import (
 // ^ a custom error type
 var ErrExpiredToken = errors.New("token has expired")
 
+// ^ a password change cuts off every token issued before it
+var ErrTokenBeforePasswordChange = errors.New("token was issued before the last password change")
+
+// ^ an access token opens the api, a refresh token only buys new access tokens
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
 type Payload struct {
 	Id        uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	TokenType string    `json:"token_type"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
-	
+	//* only set for api keys and oauth tokens, a login token is limited by its role alone
+	Scopes []string `json:"scopes,omitempty"`
+	//* the oauth client the token was issued to
+	ClientID string `json:"client_id,omitempty"`
 }
 
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+func NewPayload(username string, role string, tokenType string, duration time.Duration) (*Payload, error) {
 
 	tokenId, err := uuid.NewRandom()
 
@@ -33,6 +47,8 @@ func NewPayload(username string, duration time.Duration) (*Payload, error) {
 	payload := &Payload{
 		Id:        tokenId,
 		Username:  username,
+		Role:      role,
+		TokenType: tokenType,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
 	
@@ -48,3 +64,27 @@ func (payload *Payload) Valid() error {
 	}
 	return nil
 }
+
+// ^ ValidSince is Valid plus a check against the user's last password change,
+// ^ the plain Valid() stays as is because jwt uses it as the Claims interface
+func (payload *Payload) ValidSince(passwordChangedAt time.Time) error {
+
+	if err := payload.Valid(); err != nil {
+		return err
+	}
+
+	if payload.IssuedAt.Before(passwordChangedAt) {
+		return ErrTokenBeforePasswordChange
+	}
+	return nil
+}
+
+// ^ HasScope reports whether an api key or oauth payload was granted scope
+func (payload *Payload) HasScope(scope string) bool {
+	for _, granted := range payload.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/itsadijmbt/simple_bank/db/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadValidSince(t *testing.T) {
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+
+	//* the zero value is what password_changed_at holds for untouched users
+	require.NoError(t, payload.ValidSince(time.Time{}))
+	require.NoError(t, payload.ValidSince(payload.IssuedAt.Add(-time.Second)))
+
+	err = payload.ValidSince(payload.IssuedAt.Add(time.Second))
+	require.EqualError(t, err, ErrTokenBeforePasswordChange.Error())
+
+	expired, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, -time.Minute)
+	require.NoError(t, err)
+	require.EqualError(t, expired.ValidSince(time.Time{}), ErrExpiredToken.Error())
+}
+
+func TestPayloadHasScope(t *testing.T) {
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, time.Minute)
+	require.NoError(t, err)
+	require.False(t, payload.HasScope(util.ScopeAccountsRead))
+
+	payload.Scopes = []string{util.ScopeAccountsRead}
+	require.True(t, payload.HasScope(util.ScopeAccountsRead))
+	require.False(t, payload.HasScope(util.ScopeTransfersWrite))
+}
+
+func TestSignPayload(t *testing.T) {
+
+	pasetoMaker, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+	jwtMaker, err := NewJWTMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	for name, maker := range map[string]Maker{"paseto": pasetoMaker, "jwt": jwtMaker} {
+		t.Run(name, func(t *testing.T) {
+			payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, TokenTypeAccess, time.Minute)
+			require.NoError(t, err)
+			payload.Scopes = []string{util.ScopeAccountsRead}
+			payload.ClientID = util.RandomString(12)
+
+			token, err := maker.SignPayload(payload)
+			require.NoError(t, err)
+
+			verified, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, payload.Id, verified.Id)
+			require.Equal(t, payload.Scopes, verified.Scopes)
+			require.Equal(t, payload.ClientID, verified.ClientID)
+		})
+	}
+}
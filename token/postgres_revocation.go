@@ -0,0 +1,47 @@
+package token
+
+import (
+	"context"
+	"time"
+
+	db "github.com/itsadijmbt/simple_bank/db/sqlc"
+)
+
+// ^ PostgresRevocationStore keeps revocations in the revoked_tokens and
+// ^ user_token_revocations tables so that every api replica sees them
+type PostgresRevocationStore struct {
+	querier db.Querier
+}
+
+func NewPostgresRevocationStore(querier db.Querier) RevocationStore {
+	return &PostgresRevocationStore{querier: querier}
+}
+
+func (store *PostgresRevocationStore) Revoke(ctx context.Context, payload *Payload) error {
+	//* the row is only needed until the token would have expired anyway
+	return store.querier.RevokeToken(ctx, db.RevokeTokenParams{
+		ID:        payload.Id,
+		Username:  payload.Username,
+		ExpiresAt: payload.ExpiredAt,
+	})
+}
+
+func (store *PostgresRevocationStore) RevokeAll(ctx context.Context, username string, before time.Time) error {
+	return store.querier.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		Username:      username,
+		RevokedBefore: before,
+	})
+}
+
+func (store *PostgresRevocationStore) IsRevoked(ctx context.Context, payload *Payload) (bool, error) {
+
+	revoked, err := store.querier.IsTokenRevoked(ctx, payload.Id)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	return store.querier.IsUserTokenRevoked(ctx, db.IsUserTokenRevokedParams{
+		Username: payload.Username,
+		IssuedAt: payload.IssuedAt,
+	})
+}
@@ -0,0 +1,19 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ^ returned by the auth layer when a token was cut off before its expiry
+var ErrRevokedToken = errors.New("token has been revoked")
+
+// * RevocationStore lets the server cut off tokens before they expire.
+// * single tokens are keyed by Payload.Id, RevokeAll rejects every token of a
+// * user that was issued before the given time (logout of all sessions)
+type RevocationStore interface {
+	Revoke(ctx context.Context, payload *Payload) error
+	RevokeAll(ctx context.Context, username string, before time.Time) error
+	IsRevoked(ctx context.Context, payload *Payload) (bool, error)
+}